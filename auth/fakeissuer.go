@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FakeIssuer 開發與測試用的 OIDC 發行者 (AUTH_PROVIDER=fake)，掛在本站的 FakeIssuerPath 下：
+// 授權頁面直接輸入想登入的 Email 與姓名，不需要任何外部服務。
+// code 與 access token 就是編碼過的使用者資料，不保存任何狀態，任何人都能冒用任意帳號，切勿用於正式環境。
+//
+// 自動化測試可在授權網址加上 login_hint=Email (與選填的 name)，跳過表單直接回到 callback。
+type FakeIssuer struct {
+	// Issuer 發行者的完整網址，例如 http://localhost:8080/dev/oidc
+	Issuer string
+	// RedirectURL 唯一允許回到的 callback 網址，避免被當成任意轉址的跳板
+	RedirectURL string
+}
+
+// NewFakeIssuer 建立與目前登入設定相符的測試用發行者
+func NewFakeIssuer() *FakeIssuer {
+	if p, ok := Current.(*oidcProvider); ok {
+		return &FakeIssuer{Issuer: p.issuer, RedirectURL: p.conf.RedirectURL}
+	}
+	return &FakeIssuer{}
+}
+
+func (f *FakeIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, FakeIssuerPath) {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                   f.Issuer,
+			"authorization_endpoint":   f.Issuer + "/authorize",
+			"token_endpoint":           f.Issuer + "/token",
+			"userinfo_endpoint":        f.Issuer + "/userinfo",
+			"response_types_supported": []string{"code"},
+			"scopes_supported":         []string{"openid", "email", "profile"},
+		})
+	case "/authorize":
+		f.authorize(w, r)
+	case "/token":
+		f.token(w, r)
+	case "/userinfo":
+		f.userinfo(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeClaims code 與 access token 中帶的使用者資料
+type fakeClaims struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+func (c fakeClaims) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeFakeClaims(s string) (fakeClaims, bool) {
+	var c fakeClaims
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(raw, &c) != nil || !strings.Contains(c.Email, "@") {
+		return c, false
+	}
+	return c, true
+}
+
+var fakeLoginPage = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html><head><meta charset="UTF-8"><title>測試登入</title></head>
+<body style="font-family: sans-serif; max-width: 360px; margin: 60px auto; color: #595755;">
+<h2>測試用登入</h2>
+<p style="color: #d9534f;">這是開發用的假登入頁面，可以任意 Email 登入。</p>
+<form method="GET">
+{{ range $k, $v := .Query }}{{ if and (ne $k "login_hint") (ne $k "name") }}<input type="hidden" name="{{ $k }}" value="{{ index $v 0 }}">{{ end }}{{ end }}
+<p><input type="email" name="login_hint" placeholder="Email" required style="width: 100%;"></p>
+<p><input type="text" name="name" placeholder="姓名" style="width: 100%;"></p>
+<button type="submit">登入</button>
+</form>
+</body></html>`))
+
+// authorize 有 login_hint 時直接回到 redirect_uri，否則顯示輸入 Email 的表單；redirect_uri 必須是設定的 callback 網址
+func (f *FakeIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if f.RedirectURL == "" || q.Get("redirect_uri") != f.RedirectURL {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	claims := fakeClaims{Email: strings.TrimSpace(q.Get("login_hint")), Name: strings.TrimSpace(q.Get("name"))}
+	if claims.Email == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fakeLoginPage.Execute(w, map[string]interface{}{"Query": q})
+		return
+	}
+	if !strings.Contains(claims.Email, "@") {
+		http.Error(w, "invalid login_hint", http.StatusBadRequest)
+		return
+	}
+	if claims.Name == "" {
+		claims.Name = strings.Split(claims.Email, "@")[0]
+	}
+	redirect, err := url.Parse(f.RedirectURL)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := redirect.Query()
+	back.Set("code", claims.encode())
+	back.Set("state", q.Get("state"))
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (f *FakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := decodeFakeClaims(r.PostFormValue("code"))
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": claims.encode(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (f *FakeIssuer) userinfo(w http.ResponseWriter, r *http.Request) {
+	claims, ok := decodeFakeClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if !ok {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":            claims.Email,
+		"email":          claims.Email,
+		"email_verified": true,
+		"name":           claims.Name,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/models"
+	"grade-system/utils"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength 本機帳號密碼的最短長度
+const MinPasswordLength = 8
+
+// errBadCredentials 不區分帳號不存在或密碼錯誤，避免被用來試探帳號
+var errBadCredentials = errors.New("帳號或密碼錯誤")
+
+// localProvider 本機帳號：密碼以 bcrypt 雜湊存在 Account，適合無法連外的部署；帳號由管理員在總管理後台建立
+type localProvider struct{}
+
+func (localProvider) Name() string { return "本機帳號" }
+
+func (localProvider) Authenticate(email, password string) (Identity, error) {
+	var account models.Account
+	err := initializers.DB.Where("LOWER(email) = LOWER(?)", strings.TrimSpace(email)).First(&account).Error
+	if err != nil || account.PasswordHash == "" {
+		return Identity{}, errBadCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)) != nil {
+		return Identity{}, errBadCredentials
+	}
+	return Identity{Email: account.Email, Name: account.Name}, nil
+}
+
+// SetPassword 設定本機帳號的密碼，帳號不存在時一併建立
+func SetPassword(email, name, password string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return errors.New("請輸入正確的 Email")
+	}
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("密碼至少需要 %d 個字元", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	var account models.Account
+	if err := initializers.DB.Where("LOWER(email) = ?", email).Attrs(models.Account{Email: email, Name: name}).FirstOrCreate(&account).Error; err != nil {
+		return err
+	}
+	return initializers.DB.Model(&account).Update("password_hash", string(hash)).Error
+}
+
+// bootstrapLocalAdmins 第一次部署時，讓 ADMIN_WHITELIST 中還沒有密碼的管理員以 LOCAL_ADMIN_PASSWORD 登入
+func bootstrapLocalAdmins(password string) {
+	if password == "" {
+		return
+	}
+	for _, email := range utils.AdminWhitelist() {
+		var account models.Account
+		if initializers.DB.Where("LOWER(email) = LOWER(?)", email).First(&account).Error == nil && account.PasswordHash != "" {
+			continue
+		}
+		if err := SetPassword(email, "", password); err != nil {
+			log.Println("無法建立管理員本機帳號", email, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+)
+
+// googleProvider Google OAuth，以 userinfo API 取得 Email 與姓名
+type googleProvider struct {
+	conf *oauth2.Config
+}
+
+func newGoogleProvider(redirectURL string) *googleProvider {
+	return &googleProvider{conf: &oauth2.Config{
+		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
+		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
+		Endpoint:     google.Endpoint,
+	}}
+}
+
+func (p *googleProvider) Name() string { return "Google 帳號" }
+
+func (p *googleProvider) AuthCodeURL(state string) (string, error) {
+	return p.conf.AuthCodeURL(state), nil
+}
+
+func (p *googleProvider) Identify(ctx context.Context, code string) (Identity, error) {
+	token, err := p.conf.Exchange(ctx, code)
+	if err != nil {
+		return Identity{}, err
+	}
+	var user struct{ Email, Name string }
+	if err := fetchJSON(ctx, p.conf.Client(ctx, token), "https://www.googleapis.com/oauth2/v2/userinfo", &user); err != nil {
+		return Identity{}, err
+	}
+	if user.Email == "" {
+		return Identity{}, errors.New("Google 沒有提供 Email")
+	}
+	return Identity{Email: user.Email, Name: user.Name}, nil
+}
+
+// gitHubProvider GitHub OAuth App；帳號未公開 Email 時改用已驗證的主要 Email
+type gitHubProvider struct {
+	conf *oauth2.Config
+}
+
+func newGitHubProvider(redirectURL string) *gitHubProvider {
+	return &gitHubProvider{conf: &oauth2.Config{
+		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"read:user", "user:email"},
+		Endpoint:     github.Endpoint,
+	}}
+}
+
+func (p *gitHubProvider) Name() string { return "GitHub 帳號" }
+
+func (p *gitHubProvider) AuthCodeURL(state string) (string, error) {
+	return p.conf.AuthCodeURL(state), nil
+}
+
+func (p *gitHubProvider) Identify(ctx context.Context, code string) (Identity, error) {
+	token, err := p.conf.Exchange(ctx, code)
+	if err != nil {
+		return Identity{}, err
+	}
+	var user struct{ Login, Name, Email string }
+	if err := fetchJSON(ctx, p.conf.Client(ctx, token), "https://api.github.com/user", &user); err != nil {
+		return Identity{}, err
+	}
+	if user.Name == "" {
+		user.Name = user.Login
+	}
+	if user.Email == "" {
+		var emails []struct {
+			Email    string
+			Primary  bool
+			Verified bool
+		}
+		if err := fetchJSON(ctx, p.conf.Client(ctx, token), "https://api.github.com/user/emails", &emails); err != nil {
+			return Identity{}, err
+		}
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				user.Email = e.Email
+			}
+		}
+	}
+	if user.Email == "" {
+		return Identity{}, errors.New("GitHub 帳號沒有已驗證的 Email")
+	}
+	return Identity{Email: user.Email, Name: user.Name}, nil
+}
+
+// oidcProvider 通用 OpenID Connect (學校單一登入、測試用發行者)；
+// 端點由發行者的 /.well-known/openid-configuration 取得，使用者資料以 userinfo 端點讀取
+type oidcProvider struct {
+	name   string
+	issuer string
+	conf   *oauth2.Config
+
+	mu       sync.Mutex
+	userinfo string
+}
+
+func newOIDCProvider(name, issuer string, conf *oauth2.Config) *oidcProvider {
+	if name == "" {
+		name = "學校帳號"
+	}
+	return &oidcProvider{name: name, issuer: strings.TrimSuffix(issuer, "/"), conf: conf}
+}
+
+func (p *oidcProvider) Name() string { return p.name }
+
+// discover 第一次登入時才讀取發行者設定，發行者暫時連不上也不影響網站啟動；失敗時下次登入再試
+func (p *oidcProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.userinfo != "" {
+		return nil
+	}
+	var meta struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := fetchJSON(ctx, http.DefaultClient, p.issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return err
+	}
+	if meta.Issuer != p.issuer || meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.UserinfoEndpoint == "" {
+		return errors.New("OIDC 發行者設定不完整或與 OIDC_ISSUER 不符")
+	}
+	p.conf.Endpoint = oauth2.Endpoint{AuthURL: meta.AuthorizationEndpoint, TokenURL: meta.TokenEndpoint}
+	p.userinfo = meta.UserinfoEndpoint
+	return nil
+}
+
+func (p *oidcProvider) AuthCodeURL(state string) (string, error) {
+	if err := p.discover(context.Background()); err != nil {
+		return "", err
+	}
+	return p.conf.AuthCodeURL(state), nil
+}
+
+func (p *oidcProvider) Identify(ctx context.Context, code string) (Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return Identity{}, err
+	}
+	token, err := p.conf.Exchange(ctx, code)
+	if err != nil {
+		return Identity{}, err
+	}
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := fetchJSON(ctx, p.conf.Client(ctx, token), p.userinfo, &claims); err != nil {
+		return Identity{}, err
+	}
+	if claims.Email == "" || (claims.EmailVerified != nil && !*claims.EmailVerified) {
+		return Identity{}, errors.New("OIDC 發行者沒有提供已驗證的 Email")
+	}
+	return Identity{Email: claims.Email, Name: claims.Name}, nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// Identity 登入方式驗證後取得的使用者資料
+type Identity struct {
+	Email string
+	Name  string
+}
+
+// Provider 登入方式；每個部署以 AUTH_PROVIDER 選擇一種
+type Provider interface {
+	// Name 登入按鈕上顯示的名稱，例如「Google 帳號」
+	Name() string
+}
+
+// OAuthProvider 轉到外部頁面登入，再帶著 code 回到 /auth/callback (Google、OIDC、GitHub)
+type OAuthProvider interface {
+	Provider
+	AuthCodeURL(state string) (string, error)
+	Identify(ctx context.Context, code string) (Identity, error)
+}
+
+// PasswordProvider 在站內輸入帳號密碼登入
+type PasswordProvider interface {
+	Provider
+	Authenticate(email, password string) (Identity, error)
+}
+
+// Current 目前部署使用的登入方式 (由 Setup 設定)
+var Current Provider
+
+// kind 目前的 AUTH_PROVIDER 設定值
+var kind string
+
+// 支援的 AUTH_PROVIDER 設定值
+const (
+	ProviderGoogle = "google"
+	ProviderOIDC   = "oidc"
+	ProviderGitHub = "github"
+	ProviderLocal  = "local"
+	ProviderFake   = "fake"
+)
+
+// FakeIssuerPath 測試用 OIDC 發行者掛在站內的路徑 (AUTH_PROVIDER=fake)
+const FakeIssuerPath = "/dev/oidc"
+
+// Setup 依 AUTH_PROVIDER 建立登入方式，未設定時沿用 Google
+func Setup() error {
+	redirectURL := os.Getenv("AUTH_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = os.Getenv("GOOGLE_REDIRECT_URL")
+	}
+
+	kind = os.Getenv("AUTH_PROVIDER")
+	if kind == "" {
+		kind = ProviderGoogle
+	}
+	switch kind {
+	case ProviderGoogle:
+		Current = newGoogleProvider(redirectURL)
+	case ProviderOIDC:
+		if os.Getenv("OIDC_ISSUER") == "" {
+			return errors.New("AUTH_PROVIDER=oidc 需要設定 OIDC_ISSUER")
+		}
+		Current = newOIDCProvider(os.Getenv("OIDC_NAME"), os.Getenv("OIDC_ISSUER"), &oauth2.Config{
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+		})
+	case ProviderGitHub:
+		Current = newGitHubProvider(redirectURL)
+	case ProviderLocal:
+		Current = localProvider{}
+		bootstrapLocalAdmins(os.Getenv("LOCAL_ADMIN_PASSWORD"))
+	case ProviderFake:
+		if redirectURL == "" {
+			redirectURL = "http://localhost:8080/auth/callback"
+		}
+		issuer, err := issuerFor(redirectURL)
+		if err != nil {
+			return err
+		}
+		if err := checkFakeAllowed(redirectURL); err != nil {
+			return err
+		}
+		log.Println("⚠️ AUTH_PROVIDER=fake：任何人都能以任意 Email 登入，只能用於開發與測試")
+		Current = newOIDCProvider("測試帳號", issuer, &oauth2.Config{
+			ClientID:    "fake-client",
+			RedirectURL: redirectURL,
+			Scopes:      []string{"openid", "email", "profile"},
+		})
+	default:
+		return fmt.Errorf("不支援的 AUTH_PROVIDER「%s」", kind)
+	}
+	return nil
+}
+
+// IsFake 是否使用測試用 OIDC 發行者
+func IsFake() bool {
+	return kind == ProviderFake
+}
+
+// IsLocal 是否使用本機帳號密碼登入
+func IsLocal() bool {
+	return kind == ProviderLocal
+}
+
+// issuerFor 測試用發行者與本站同源，網址由登入完成後的 callback 網址推得
+func issuerFor(redirectURL string) (string, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("無法解析 AUTH_REDIRECT_URL「%s」", redirectURL)
+	}
+	return u.Scheme + "://" + u.Host + FakeIssuerPath, nil
+}
+
+// checkFakeAllowed 測試用登入讓任何人冒用任意帳號 (包含管理員)，只允許在本機開發時使用：
+// gin 為 release 模式或 callback 不在 localhost 時拒絕啟動，除非明確設定 AUTH_FAKE_ALLOW=true
+func checkFakeAllowed(redirectURL string) error {
+	if allow, _ := strconv.ParseBool(os.Getenv("AUTH_FAKE_ALLOW")); allow {
+		return nil
+	}
+	if gin.Mode() == gin.ReleaseMode {
+		return errors.New("AUTH_PROVIDER=fake 不能在 release 模式下使用 (確定要啟用請設定 AUTH_FAKE_ALLOW=true)")
+	}
+	u, _ := url.Parse(redirectURL)
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return nil
+	}
+	return fmt.Errorf("AUTH_PROVIDER=fake 只能在 localhost 使用，AUTH_REDIRECT_URL「%s」不是本機網址 (確定要啟用請設定 AUTH_FAKE_ALLOW=true)", redirectURL)
+}
+
+// fetchJSON 讀取 JSON API；userinfo 等需要登入的 API 以 conf.Client 帶上 access token
+func fetchJSON(ctx context.Context, client *http.Client, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 回應 %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"grade-system/models"
+	"grade-system/utils"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// enrollmentSummary 學生首頁上每門課程的成績摘要
+type enrollmentSummary struct {
+	Course   models.Course
+	Student  models.Student
+	Graded   int // 已有成績的項目數
+	Total    float64
+	Letter   string
+	ReadOnly bool
+}
+
+// currentAccount 讀取目前登入的帳號
+func currentAccount(c *gin.Context) (models.Account, bool) {
+	var account models.Account
+	id := sessions.Default(c).Get(middleware.AccountSessionKey)
+	if id == nil {
+		return account, false
+	}
+	err := initializers.DB.First(&account, id).Error
+	return account, err == nil
+}
+
+// findEnrollment 找帳號在課程中的綁定 (只讀取，不會建立綁定)
+func findEnrollment(account models.Account, subject string) (models.Student, bool) {
+	var s models.Student
+	err := initializers.DB.Scopes(utils.FilterSubject(subject)).Where("email = ?", account.Email).First(&s).Error
+	return s, err == nil
+}
+
+// suggestEnrollment 帳號在其他課程用過的學號若出現在這門課的名單中，註冊頁面直接提供以該學號綁定，由學生按下確認後才綁定
+func suggestEnrollment(account models.Account, subject string) (models.Roster, bool) {
+	var roster models.Roster
+	var sids []string
+	initializers.DB.Model(&models.Student{}).Where("email = ?", account.Email).Distinct("student_id").Pluck("student_id", &sids)
+	if len(sids) == 0 {
+		return roster, false
+	}
+	err := initializers.DB.Scopes(utils.FilterSubject(subject)).Where("student_id IN ?", sids).First(&roster).Error
+	return roster, err == nil
+}
+
+// errStudentIDTaken 名單中的學號已經被其他帳號綁定
+var errStudentIDTaken = errors.New("此學號已經被註冊過了")
+
+// bindStudent 以名單中的學號為帳號建立課程綁定
+func bindStudent(account models.Account, roster models.Roster) (models.Student, error) {
+	var exist models.Student
+	if initializers.DB.Where("student_id = ? AND subject = ?", roster.StudentID, roster.Subject).First(&exist).Error == nil {
+		return exist, errStudentIDTaken
+	}
+
+	// 回收桶中同一學號或 Email 的舊綁定會擋住唯一索引，重新綁定前先永久刪除
+	initializers.DB.Unscoped().
+		Where("subject = ? AND deleted_at IS NOT NULL", roster.Subject).
+		Where("student_id = ? OR email = ?", roster.StudentID, account.Email).
+		Delete(&models.Student{})
+
+	s := models.Student{
+		Email:     account.Email,
+		Name:      account.Name,
+		StudentID: roster.StudentID,
+		Class:     roster.Class,
+		Subject:   roster.Subject,
+	}
+	return s, initializers.DB.Create(&s).Error
+}
+
+// enrolledCourses 帳號綁定的所有課程，供課程切換選單使用
+func enrolledCourses(email string) []models.Course {
+	var courses []models.Course
+	initializers.DB.Where("code IN (?)", initializers.DB.Model(&models.Student{}).Select("subject").Where("email = ?", email)).
+		Order("status asc, semester desc, code asc").Find(&courses)
+	return courses
+}
+
+// loadEnrollments 帳號綁定的每門課程及目前的總分與等第
+func loadEnrollments(email string) []enrollmentSummary {
+	var bindings []models.Student
+	initializers.DB.Where("email = ?", email).Find(&bindings)
+	bySubject := make(map[string]models.Student, len(bindings))
+	for _, s := range bindings {
+		bySubject[s.Subject] = s
+	}
+
+	var summaries []enrollmentSummary
+	for _, course := range enrolledCourses(email) {
+		s := bySubject[course.Code]
+		var grades []models.Grade
+		initializers.DB.Where("subject = ? AND student_id = ?", course.Code, s.StudentID).
+			Where("item_name NOT IN ? AND needs_review = ?", IgnoredGradeItems, false).
+			Order("id asc").
+			Find(&grades)
+		scheme := utils.LoadScheme(course.Code)
+		eval := scheme.Evaluate(grades)
+		summaries = append(summaries, enrollmentSummary{
+			Course:   course,
+			Student:  s,
+			Graded:   len(grades),
+			Total:    eval.Total,
+			Letter:   scheme.Letters.Lookup(eval.Total).Letter,
+			ReadOnly: utils.CourseReadOnly(course),
+		})
+	}
+	return summaries
+}
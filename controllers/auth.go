@@ -1,64 +1,176 @@
 package controllers
 
 import (
-	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
+	"grade-system/auth"
 	"grade-system/initializers"
+	"grade-system/middleware"
 	"grade-system/models"
 	"grade-system/utils"
-	"io/ioutil"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
+// Login 記下從哪門課程登入，登入完成後回到原處；依部署的登入方式轉到外部頁面或顯示密碼表單
 func Login(c *gin.Context) {
-	url := initializers.GoogleOauthConfig.AuthCodeURL("state")
+	startLogin(c, false)
+}
+
+// AdminLogin 從總管理後台登入，只有管理員可以通過
+func AdminLogin(c *gin.Context) {
+	startLogin(c, true)
+}
+
+// startLogin 每次登入都重新記下是否從總管理後台進入，避免中途放棄的後台登入影響之後的一般登入
+func startLogin(c *gin.Context, asAdmin bool) {
+	session := sessions.Default(c)
+	if asAdmin {
+		session.Set("login_admin", true)
+	} else {
+		session.Delete("login_admin")
+	}
+	session.Set("login_course", middleware.CourseCode(c))
+	session.Set("login_path", middleware.CoursePath(c))
+
+	provider, ok := auth.Current.(auth.OAuthProvider)
+	if !ok {
+		session.Save()
+		showLocalLogin(c, http.StatusOK, "")
+		return
+	}
+	state := newLoginState()
+	session.Set("login_state", state)
+	session.Save()
+	url, err := provider.AuthCodeURL(state)
+	if err != nil {
+		log.Println("登入設定錯誤：", err)
+		c.String(502, "❌ 目前無法連線到登入服務，請稍後再試")
+		return
+	}
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
+// Callback 外部登入完成後回到這裡，確認 state 後取得使用者資料
 func Callback(c *gin.Context) {
-	token, err := initializers.GoogleOauthConfig.Exchange(context.Background(), c.Query("code"))
+	provider, ok := auth.Current.(auth.OAuthProvider)
+	session := sessions.Default(c)
+	state, _ := session.Get("login_state").(string)
+	session.Delete("login_state")
+	if !ok || state == "" || c.Query("state") != state {
+		session.Save()
+		c.Redirect(302, "/")
+		return
+	}
+
+	identity, err := provider.Identify(c.Request.Context(), c.Query("code"))
 	if err != nil {
+		log.Println("登入失敗：", err)
+		session.Save()
+		c.Redirect(302, "/")
+		return
+	}
+	completeLogin(c, identity)
+}
+
+// LocalLogin 本機帳號的密碼表單送出
+func LocalLogin(c *gin.Context) {
+	provider, ok := auth.Current.(auth.PasswordProvider)
+	if !ok {
 		c.Redirect(302, "/")
 		return
 	}
+	identity, err := provider.Authenticate(c.PostForm("email"), c.PostForm("password"))
+	if err != nil {
+		showLocalLogin(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	completeLogin(c, identity)
+}
 
-	resp, _ := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + token.AccessToken)
-	defer resp.Body.Close()
-	data, _ := ioutil.ReadAll(resp.Body)
+// showLocalLogin 本機帳號的登入頁面
+func showLocalLogin(c *gin.Context, code int, errMsg string) {
+	c.HTML(code, "login.html", gin.H{
+		"Provider": auth.Current.Name(),
+		"Error":    errMsg,
+		"Base":     middleware.CoursePath(c),
+		"AppName":  initializers.AppName,
+	})
+}
 
-	var gUser struct{ Email, Name string }
-	json.Unmarshal(data, &gUser)
+// newLoginState 防止 CSRF 的隨機 state，存在 session 中於 Callback 比對
+func newLoginState() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// completeLogin 登入方式確認身分後的共同流程：管理員進總管理後台，其他人以帳號登入並回到原本的課程
+func completeLogin(c *gin.Context, identity auth.Identity) {
 	session := sessions.Default(c)
 
-	if initializers.IsAdminMode {
-		if !utils.IsTeacher(gUser.Email) {
-			c.String(403, "🚫 抱歉，只有老師可以登入此後台。")
+	subject, _ := session.Get("login_course").(string)
+	base, _ := session.Get("login_path").(string)
+	asAdmin, _ := session.Get("login_admin").(bool)
+	session.Delete("login_course")
+	session.Delete("login_path")
+	session.Delete("login_admin")
+
+	if initializers.IsAdminMode || asAdmin {
+		if !utils.IsAdmin(identity.Email) {
+			session.Save()
+			c.String(403, "🚫 抱歉，只有管理員可以登入此後台。")
 			return
 		}
-		session.Set("user_id", "ADMIN_"+gUser.Email)
+		session.Set("user_id", "ADMIN_"+identity.Email)
 		session.Save()
-		c.Redirect(http.StatusSeeOther, "/")
+		c.Redirect(http.StatusSeeOther, adminHome())
 		return
 	}
 
-	var s models.Student
-	result := initializers.DB.Scopes(utils.FilterSubject).Where("email = ?", gUser.Email).First(&s)
+	// 所有課程共用同一個帳號，第一次登入時建立
+	account := models.Account{Email: identity.Email}
+	if err := initializers.DB.Where("email = ?", identity.Email).Attrs(models.Account{Name: identity.Name}).FirstOrCreate(&account).Error; err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	session.Set(middleware.AccountSessionKey, account.ID)
+	session.Save()
 
-	if result.Error == gorm.ErrRecordNotFound || s.StudentID == "" {
-		session.Set("temp_email", gUser.Email)
-		session.Set("temp_name", gUser.Name)
-		session.Save()
-		c.Redirect(http.StatusSeeOther, "/register")
+	if subject != "" {
+		if _, bound := findEnrollment(account, subject); !bound {
+			c.Redirect(http.StatusSeeOther, base+"/register")
+			return
+		}
+	}
+	c.Redirect(http.StatusSeeOther, base+"/")
+}
+
+// SetLocalPassword 管理員建立本機帳號或重設密碼 (AUTH_PROVIDER=local)
+func SetLocalPassword(c *gin.Context) {
+	if !auth.IsLocal() {
+		c.String(400, "❌ 此部署未使用本機帳號登入")
 		return
 	}
+	email := c.PostForm("email")
+	if err := auth.SetPassword(email, strings.TrimSpace(c.PostForm("name")), c.PostForm("password")); err != nil {
+		c.String(400, "❌ "+err.Error())
+		return
+	}
+	setFlash(c, "🔑 已設定 "+strings.ToLower(strings.TrimSpace(email))+" 的密碼")
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
 
-	session.Set("user_id", s.ID)
-	session.Save()
-	c.Redirect(http.StatusSeeOther, "/")
+// adminHome 總管理後台的網址；APP_MODE=admin 的部署首頁就是後台
+func adminHome() string {
+	if initializers.IsAdminMode {
+		return "/"
+	}
+	return "/admin"
 }
 
 func Logout(c *gin.Context) {
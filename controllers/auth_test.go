@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"fmt"
+	"grade-system/auth"
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newMockDB 以 sqlmock 取代 initializers.DB，SQL 以正規表示式比對
+func newMockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	initializers.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock
+}
+
+// newFakeLoginServer 以 AUTH_PROVIDER=fake 啟動只有登入路由的測試站，資料庫以 sqlmock 取代
+func newFakeLoginServer(t *testing.T) (*httptest.Server, sqlmock.Sqlmock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	mock := newMockDB(t)
+
+	// 發行者網址要等伺服器啟動後才知道，路由在 auth.Setup 之後才建立
+	var handler http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("AUTH_PROVIDER", auth.ProviderFake)
+	t.Setenv("AUTH_REDIRECT_URL", srv.URL+"/auth/callback")
+	if err := auth.Setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test-secret"))))
+	r.GET("/login", Login)
+	r.GET("/auth/callback", Callback)
+	r.Any(auth.FakeIssuerPath+"/*path", gin.WrapH(auth.NewFakeIssuer()))
+	r.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, fmt.Sprint(sessions.Default(c).Get(middleware.AccountSessionKey)))
+	})
+	handler = r
+	return srv, mock
+}
+
+// newLoginClient 保留 cookie、不自動跟隨轉址的瀏覽器，逐步檢查每一次轉址
+func newLoginClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// follow 送出 GET 並回傳狀態碼與轉址目標
+func follow(t *testing.T, client *http.Client, target string) (int, *url.URL) {
+	t.Helper()
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	location, _ := resp.Location()
+	return resp.StatusCode, location
+}
+
+// fakeCallback 從 /login 開始，以 login_hint 略過測試發行者的表單，回傳帶著 code 與 state 的 callback 網址
+func fakeCallback(t *testing.T, client *http.Client, srv *httptest.Server, email string) *url.URL {
+	t.Helper()
+	code, authorize := follow(t, client, srv.URL+"/login")
+	if code != http.StatusTemporaryRedirect || authorize == nil {
+		t.Fatalf("/login 回應 %d，應轉到發行者的授權頁面", code)
+	}
+	q := authorize.Query()
+	q.Set("login_hint", email)
+	authorize.RawQuery = q.Encode()
+
+	code, callback := follow(t, client, authorize.String())
+	if code != http.StatusFound || callback == nil || callback.Path != "/auth/callback" {
+		t.Fatalf("授權頁面回應 %d (%v)，應轉回 /auth/callback", code, callback)
+	}
+	return callback
+}
+
+func whoami(t *testing.T, client *http.Client, srv *httptest.Server) string {
+	t.Helper()
+	resp, err := client.Get(srv.URL + "/whoami")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestFakeLoginFlow(t *testing.T) {
+	srv, mock := newFakeLoginServer(t)
+	client := newLoginClient(t)
+
+	callback := fakeCallback(t, client, srv, "student@example.com")
+
+	// 第一次登入時建立帳號
+	mock.ExpectQuery(`SELECT \* FROM "accounts" WHERE email = \$1`).
+		WithArgs("student@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "accounts"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	code, location := follow(t, client, callback.String())
+	if code != http.StatusSeeOther || location == nil || location.Path != "/" {
+		t.Fatalf("callback 回應 %d (%v)，應登入後回到首頁", code, location)
+	}
+	if got := whoami(t, client, srv); got != "7" {
+		t.Errorf("session 中的帳號為 %q，應為 7", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFakeLoginRejectsStateMismatch(t *testing.T) {
+	srv, mock := newFakeLoginServer(t)
+	client := newLoginClient(t)
+
+	callback := fakeCallback(t, client, srv, "student@example.com")
+	q := callback.Query()
+	q.Set("state", "forged")
+	callback.RawQuery = q.Encode()
+
+	code, location := follow(t, client, callback.String())
+	if code != http.StatusFound || location == nil || location.Path != "/" {
+		t.Fatalf("callback 回應 %d (%v)，state 不符時應直接回到首頁", code, location)
+	}
+	if got := whoami(t, client, srv); got != "<nil>" {
+		t.Errorf("state 不符仍登入為帳號 %q", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFakeIssuerRejectsOtherRedirect(t *testing.T) {
+	srv, _ := newFakeLoginServer(t)
+	client := newLoginClient(t)
+
+	code, authorize := follow(t, client, srv.URL+"/login")
+	if code != http.StatusTemporaryRedirect || authorize == nil {
+		t.Fatalf("/login 回應 %d，應轉到發行者的授權頁面", code)
+	}
+	q := authorize.Query()
+	q.Set("login_hint", "student@example.com")
+	q.Set("redirect_uri", "https://evil.example.com/auth/callback")
+	authorize.RawQuery = q.Encode()
+
+	if code, _ := follow(t, client, authorize.String()); code != http.StatusBadRequest {
+		t.Errorf("redirect_uri 不是設定的 callback 網址時回應 %d，應為 400", code)
+	}
+}
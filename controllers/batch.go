@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/models"
+	"grade-system/utils"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recentBatchLimit 教師後台列出的最近匯入筆數
+const recentBatchLimit = 10
+
+// loadRecentBatches 讀取科目最近的匯入紀錄，新的在前
+func loadRecentBatches(subject string) []models.ImportBatch {
+	var batches []models.ImportBatch
+	initializers.DB.Where("subject = ?", subject).Order("id desc").Limit(recentBatchLimit).Find(&batches)
+	return batches
+}
+
+// applyRosterImport 寫入名單並記下每位學生原本的班級、姓名與狀態，需在交易中呼叫
+func applyRosterImport(tx *gorm.DB, batch *models.ImportBatch, rosters []models.Roster) error {
+	var existing []models.Roster
+	tx.Where("subject = ?", batch.Subject).Find(&existing)
+	oldRosters := make(map[string]models.Roster, len(existing))
+	for _, r := range existing {
+		oldRosters[r.StudentID] = r
+	}
+
+	var changed []models.Roster
+	var history []models.RosterHistory
+	for _, r := range rosters {
+		entry := models.RosterHistory{BatchID: batch.ID, Subject: batch.Subject, StudentID: r.StudentID, Action: models.HistoryActionCreate}
+		if old, ok := oldRosters[r.StudentID]; ok {
+			enrolled := utils.RosterEnrolled(old.Status)
+			if enrolled && old.Class == r.Class && old.Name == r.Name {
+				continue
+			}
+			// 已退選或封存的學生重新出現在名單中時恢復修課，旁聽與未完成保留原本的狀態
+			if enrolled {
+				r.Status = old.Status
+			} else {
+				r.Status = models.RosterActive
+			}
+			entry = rosterHistoryEntry(batch, models.HistoryActionUpdate, old)
+			batch.Updated++
+		} else {
+			batch.Created++
+		}
+		changed = append(changed, r)
+		history = append(history, entry)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// 回收桶中的同一位學生由 upsert 還原，並換上檔案中的班級與姓名
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "student_id"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"class", "name", "status", "updated_at", "deleted_at"}),
+	}).CreateInBatches(&changed, importBatchSize).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.CreateInBatches(&history, importBatchSize).Error; err != nil {
+		return err
+	}
+	return tx.Model(batch).Updates(map[string]interface{}{"created": batch.Created, "updated": batch.Updated}).Error
+}
+
+// RollbackImportBatch 將一次匯入復原成匯入前的狀態；之後對同一筆資料的修改也會一併被蓋掉
+func RollbackImportBatch(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	var batch models.ImportBatch
+	if err := initializers.DB.Where("id = ? AND subject = ?", c.PostForm("id"), targetSubject).First(&batch).Error; err != nil {
+		c.String(400, "❌ 找不到此匯入紀錄")
+		return
+	}
+	if batch.RolledBackAt != nil {
+		c.String(400, "❌ 此匯入已經復原過了")
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		switch batch.Kind {
+		case models.ImportKindGrades:
+			err = rollbackGradeBatch(tx, batch, newGradeAudit(c, targetSubject, models.HistorySourceRollback))
+		case models.ImportKindRoster:
+			// 名單同步刪除學生時會一併刪除成績，成績的部分記在成績異動紀錄中
+			if err = rollbackRosterBatch(tx, batch); err == nil {
+				err = rollbackGradeBatch(tx, batch, newGradeAudit(c, targetSubject, models.HistorySourceRollback))
+			}
+		default:
+			err = errors.New("不支援的匯入種類")
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&batch).Updates(map[string]interface{}{"rolled_back_at": time.Now(), "rolled_back_by": teacherEmail(c)}).Error
+	})
+	if err != nil {
+		c.String(500, "❌ 復原失敗，資料未變更："+err.Error())
+		return
+	}
+	setFlash(c, fmt.Sprintf("↩️ 已復原%s匯入「%s」", utils.ImportKindLabel(batch.Kind), batch.FileName))
+	redirectBack(c)
+}
+
+// rollbackGradeBatch 刪除這批新增的成績、把修改或刪除的成績 (含是否待確認) 與滿分改回原值
+func rollbackGradeBatch(tx *gorm.DB, batch models.ImportBatch, audit *gradeAudit) error {
+	var history []models.GradeHistory
+	tx.Where("batch_id = ?", batch.ID).Order("id asc").Find(&history)
+
+	var current []models.Grade
+	tx.Where("subject = ?", batch.Subject).Find(&current)
+	currentGrades := make(map[string]models.Grade, len(current))
+	for _, g := range current {
+		currentGrades[g.StudentID+"\x00"+g.ItemName] = g
+	}
+
+	var restore []models.Grade
+	var remove [][]interface{}
+	for _, h := range history {
+		cur, ok := currentGrades[h.StudentID+"\x00"+h.ItemName]
+		switch h.Action {
+		case models.HistoryActionCreate:
+			if ok {
+				audit.Delete(cur)
+				remove = append(remove, []interface{}{h.StudentID, h.ItemName})
+			}
+		case models.HistoryActionUpdate, models.HistoryActionDelete:
+			var old *models.Grade
+			if ok {
+				old = &cur
+			}
+			audit.Change(h.StudentID, h.ItemName, old, h.OldScore, h.OldStatus)
+			restore = append(restore, models.Grade{StudentID: h.StudentID, ItemName: h.ItemName, Score: h.OldScore, Status: h.OldStatus, NeedsReview: h.OldNeedsReview, Subject: batch.Subject})
+		}
+	}
+
+	// 新增的成績以軟刪除移除，與手動刪除一致
+	for part := range slices.Chunk(remove, importBatchSize) {
+		if err := tx.Where("subject = ? AND (student_id, item_name) IN ?", batch.Subject, part).Delete(&models.Grade{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(restore) > 0 {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "student_id"}, {Name: "item_name"}, {Name: "subject"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "status", "needs_review", "updated_at", "deleted_at"}),
+		}).CreateInBatches(&restore, importBatchSize).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// 滿分設定：原本沒有設定的項目改回預設滿分，分類與調分設定保留
+	var itemHistory []models.GradeItemHistory
+	tx.Where("batch_id = ?", batch.ID).Find(&itemHistory)
+	for _, h := range itemHistory {
+		maxPoints := h.OldMaxPoints
+		if h.Action == models.HistoryActionCreate {
+			maxPoints = utils.DefaultMaxPoints
+		}
+		err := tx.Model(&models.GradeItem{}).Where("subject = ? AND item_name = ?", batch.Subject, h.ItemName).Update("max_points", maxPoints).Error
+		if err != nil {
+			return err
+		}
+	}
+	return audit.Save(tx)
+}
+
+// rollbackRosterBatch 刪除這批新增的學生、把修改過的班級、姓名與狀態改回原值，並重建同步時刪除的學生
+func rollbackRosterBatch(tx *gorm.DB, batch models.ImportBatch) error {
+	var history []models.RosterHistory
+	tx.Where("batch_id = ?", batch.ID).Find(&history)
+
+	var restore []models.Roster
+	var remove []string
+	for _, h := range history {
+		switch h.Action {
+		case models.HistoryActionCreate:
+			remove = append(remove, h.StudentID)
+		case models.HistoryActionUpdate, models.HistoryActionDelete:
+			restore = append(restore, models.Roster{StudentID: h.StudentID, Class: h.OldClass, Name: h.OldName, Status: h.OldStatus, Subject: batch.Subject})
+		}
+	}
+
+	// 新增的學生以軟刪除移到回收桶，與其他刪除一致
+	for part := range slices.Chunk(remove, importBatchSize) {
+		if err := tx.Where("subject = ? AND student_id IN ?", batch.Subject, part).Delete(&models.Roster{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(restore) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "student_id"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"class", "name", "status", "updated_at", "deleted_at"}),
+	}).CreateInBatches(&restore, importBatchSize).Error
+}
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"errors"
+	"grade-system/initializers"
+	"grade-system/models"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// courseCodePattern 課程代碼會出現在網址中，只允許小寫英數字、底線與連字號
+var courseCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// loadCourses 列出所有課程，開課中的在前，其次依學期 (新的在前) 與代碼排序
+func loadCourses() []models.Course {
+	var courses []models.Course
+	initializers.DB.Order("status asc, semester desc, code asc").Find(&courses)
+	return courses
+}
+
+// loadTerms 列出所有學期，新的在前
+func loadTerms() []models.Term {
+	var terms []models.Term
+	initializers.DB.Order("code desc").Find(&terms)
+	return terms
+}
+
+// findCourse 以課程代碼 (即其他資料表的 Subject) 找課程
+func findCourse(code string) (models.Course, bool) {
+	var course models.Course
+	err := initializers.DB.Where("code = ?", code).First(&course).Error
+	return course, err == nil
+}
+
+// courseFromForm 讀取課程表單中可修改的欄位
+func courseFromForm(c *gin.Context, course *models.Course) error {
+	course.Name = strings.TrimSpace(c.PostForm("name"))
+	course.Semester = strings.TrimSpace(c.PostForm("semester"))
+	if course.Name == "" {
+		return errors.New("請輸入課程名稱")
+	}
+	if course.Semester != "" {
+		var term models.Term
+		if err := initializers.DB.Where("code = ?", course.Semester).First(&term).Error; err != nil {
+			return errors.New("找不到學期「" + course.Semester + "」")
+		}
+	}
+	return nil
+}
+
+// CreateCourse 新增課程，課程代碼建立後不可修改
+func CreateCourse(c *gin.Context) {
+	course := models.Course{Code: strings.ToLower(strings.TrimSpace(c.PostForm("code")))}
+	if !courseCodePattern.MatchString(course.Code) {
+		c.String(400, "❌ 課程代碼只能使用小寫英文、數字、底線與連字號")
+		return
+	}
+	if err := courseFromForm(c, &course); err != nil {
+		c.String(400, "❌ "+err.Error())
+		return
+	}
+	if _, exists := findCourse(course.Code); exists {
+		c.String(400, "❌ 課程代碼「"+course.Code+"」已經存在")
+		return
+	}
+	if err := initializers.DB.Create(&course).Error; err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, "✅ 已新增課程「"+course.Name+"」")
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
+
+// UpdateCourse 修改課程名稱與學期
+func UpdateCourse(c *gin.Context) {
+	course, ok := findCourse(c.PostForm("code"))
+	if !ok {
+		c.String(404, "❌ 找不到此課程")
+		return
+	}
+	if err := courseFromForm(c, &course); err != nil {
+		c.String(400, "❌ "+err.Error())
+		return
+	}
+	err := initializers.DB.Model(&course).Updates(map[string]interface{}{"name": course.Name, "semester": course.Semester}).Error
+	if err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, "✅ 已更新課程「"+course.Name+"」")
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
+
+// SetCourseArchived 封存或重新開啟課程；封存不會刪除任何資料
+func SetCourseArchived(c *gin.Context) {
+	course, ok := findCourse(c.PostForm("code"))
+	if !ok {
+		c.String(404, "❌ 找不到此課程")
+		return
+	}
+	status, msg := models.CourseArchived, "📦 已封存課程「"+course.Name+"」"
+	if c.PostForm("archived") != "1" {
+		status, msg = models.CourseActive, "✅ 已重新開啟課程「"+course.Name+"」"
+	}
+	if err := initializers.DB.Model(&course).Update("status", status).Error; err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, msg)
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
+
+// CreateTerm 新增學期
+func CreateTerm(c *gin.Context) {
+	term := models.Term{Code: strings.TrimSpace(c.PostForm("code")), Name: strings.TrimSpace(c.PostForm("name"))}
+	if term.Code == "" {
+		c.String(400, "❌ 請輸入學期代碼")
+		return
+	}
+	if term.Name == "" {
+		term.Name = term.Code
+	}
+	var existing models.Term
+	if initializers.DB.Where("code = ?", term.Code).First(&existing).Error == nil {
+		c.String(400, "❌ 學期「"+term.Code+"」已經存在")
+		return
+	}
+	if err := initializers.DB.Create(&term).Error; err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, "✅ 已新增學期「"+term.Name+"」")
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
+
+// SetTermArchived 封存或重新開啟學期；封存後該學期的課程老師與學生都只能瀏覽
+func SetTermArchived(c *gin.Context) {
+	var term models.Term
+	if err := initializers.DB.Where("code = ?", c.PostForm("code")).First(&term).Error; err != nil {
+		c.String(404, "❌ 找不到此學期")
+		return
+	}
+	status, msg := models.CourseArchived, "📦 已封存學期「"+term.Name+"」，該學期的課程改為唯讀"
+	if c.PostForm("archived") != "1" {
+		status, msg = models.CourseActive, "✅ 已重新開啟學期「"+term.Name+"」"
+	}
+	if err := initializers.DB.Model(&term).Update("status", status).Error; err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, msg)
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
+
+// RolloverCourse 將課程延續到下學期：以新代碼建立課程，沿用名稱、課程人員、評分方式、等第與欄位對應，
+// 名單與成績從空白開始；原課程封存為唯讀
+func RolloverCourse(c *gin.Context) {
+	old, ok := findCourse(c.PostForm("code"))
+	if !ok {
+		c.String(404, "❌ 找不到此課程")
+		return
+	}
+	course := models.Course{Code: strings.ToLower(strings.TrimSpace(c.PostForm("new_code"))), Name: old.Name}
+	if !courseCodePattern.MatchString(course.Code) {
+		c.String(400, "❌ 課程代碼只能使用小寫英文、數字、底線與連字號")
+		return
+	}
+	if _, exists := findCourse(course.Code); exists {
+		c.String(400, "❌ 課程代碼「"+course.Code+"」已經存在")
+		return
+	}
+	course.Semester = strings.TrimSpace(c.PostForm("semester"))
+	var term models.Term
+	if err := initializers.DB.Where("code = ?", course.Semester).First(&term).Error; err != nil {
+		c.String(400, "❌ 請選擇新課程的學期")
+		return
+	}
+	if term.Status == models.CourseArchived || course.Semester == old.Semester {
+		c.String(400, "❌ 請選擇尚未封存的下一個學期")
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&course).Error; err != nil {
+			return err
+		}
+		if err := copyGradingScheme(tx, old.Code, course.Code); err != nil {
+			return err
+		}
+		if err := copyCourseStaff(tx, old.Code, course.Code); err != nil {
+			return err
+		}
+		return tx.Model(&old).Update("status", models.CourseArchived).Error
+	})
+	if err != nil {
+		c.String(500, "❌ 延續課程失敗，資料未變更："+err.Error())
+		return
+	}
+	setFlash(c, "✅ 已建立「"+course.Name+"」"+term.Name+" 的課程 ("+course.Code+")，原課程已封存")
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
+
+// copyGradingScheme 複製評分分類、項目滿分與分類、等第門檻與欄位對應；調分是針對單次考試，不複製
+func copyGradingScheme(tx *gorm.DB, from, to string) error {
+	var categories []models.GradeCategory
+	tx.Where("subject = ?", from).Order("sort_order asc").Find(&categories)
+	categoryIDs := make(map[uint]uint, len(categories))
+	for _, cat := range categories {
+		oldID := cat.ID
+		cat.Model, cat.Subject = gorm.Model{}, to
+		if err := tx.Create(&cat).Error; err != nil {
+			return err
+		}
+		categoryIDs[oldID] = cat.ID
+	}
+
+	var items []models.GradeItem
+	tx.Where("subject = ?", from).Find(&items)
+	for _, item := range items {
+		if item.CategoryID != nil {
+			id := categoryIDs[*item.CategoryID]
+			item.CategoryID = &id
+		}
+		item.Model, item.Subject, item.CurveType, item.CurveParam = gorm.Model{}, to, "", 0
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+	}
+
+	var cutoffs []models.LetterCutoff
+	tx.Where("subject = ?", from).Find(&cutoffs)
+	for _, cut := range cutoffs {
+		cut.Model, cut.Subject = gorm.Model{}, to
+		if err := tx.Create(&cut).Error; err != nil {
+			return err
+		}
+	}
+
+	var mappings []models.ColumnMapping
+	tx.Where("subject = ?", from).Find(&mappings)
+	for _, m := range mappings {
+		m.Model, m.Subject = gorm.Model{}, to
+		if err := tx.Create(&m).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
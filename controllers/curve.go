@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"grade-system/models"
+	"grade-system/utils"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CurveRow 調分預覽表格中的一列
+type CurveRow struct {
+	StudentID string
+	Raw       float64
+	Current   float64
+	Preview   float64
+}
+
+// ShowCurvePreview 預覽某個評量項目套用調分後的分布，尚未寫入資料庫
+func ShowCurvePreview(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	itemName := strings.TrimSpace(c.Query("item_name"))
+	if itemName == "" {
+		c.String(400, "❌ 缺少評量項目名稱")
+		return
+	}
+
+	scheme := utils.LoadScheme(targetSubject)
+	maxPoints := scheme.MaxPoints(itemName)
+	current := scheme.Items[itemName]
+
+	// 未指定調分方式時，預覽目前已套用的設定
+	curveType := current.CurveType
+	param := current.CurveParam
+	if _, ok := c.GetQuery("curve_type"); ok {
+		curveType = c.Query("curve_type")
+		param, _ = strconv.ParseFloat(c.Query("param"), 64)
+	}
+
+	var rows []CurveRow
+	highestRaw := 0.0
+	for _, g := range loadClassGrades(targetSubject) {
+		if g.ItemName != itemName || g.Status != models.GradeScored {
+			continue
+		}
+		rows = append(rows, CurveRow{StudentID: g.StudentID, Raw: g.Score, Current: scheme.EffectiveScore(itemName, g.Score)})
+		highestRaw = max(highestRaw, g.Score)
+	}
+	if curveType == utils.CurveLinear {
+		param = utils.LinearCurveFactor(highestRaw, maxPoints)
+	}
+
+	rawScores := make(map[string]float64, len(rows))
+	previewScores := make(map[string]float64, len(rows))
+	for i := range rows {
+		rows[i].Preview = utils.ApplyCurve(curveType, param, rows[i].Raw, maxPoints)
+		rawScores[rows[i].StudentID] = rows[i].Raw
+		previewScores[rows[i].StudentID] = rows[i].Preview
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].StudentID < rows[j].StudentID })
+
+	c.HTML(200, "curve.html", gin.H{
+		"ItemName":     itemName,
+		"MaxPoints":    maxPoints,
+		"CurveType":    curveType,
+		"Param":        param,
+		"PreviewLabel": utils.CurveLabel(curveType, param),
+		"CurrentLabel": utils.CurveLabel(current.CurveType, current.CurveParam),
+		"Rows":         rows,
+		"RawStats":     utils.ComputeClassStats(rawScores),
+		"PreviewStats": utils.ComputeClassStats(previewScores),
+		"RawHist":      utils.Histogram(rawScores, maxPoints),
+		"PreviewHist":  utils.Histogram(previewScores, maxPoints),
+		"HistLabels":   utils.HistogramLabels,
+		"Subject":      targetSubject,
+		"AppName":      initializers.AppName,
+		"Base":         middleware.CoursePath(c),
+	})
+}
+
+// SaveCurve 將調分設定存到評量項目上；原始分數保留，curve_type 留空即還原
+func SaveCurve(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	itemName := strings.TrimSpace(c.PostForm("item_name"))
+	curveType := c.PostForm("curve_type")
+	if itemName == "" {
+		c.String(400, "❌ 缺少評量項目名稱")
+		return
+	}
+	switch curveType {
+	case utils.CurveNone, utils.CurveFlat, utils.CurveLinear, utils.CurveSqrt:
+	default:
+		c.String(400, "❌ 不支援的調分方式")
+		return
+	}
+
+	scheme := utils.LoadScheme(targetSubject)
+	maxPoints := scheme.MaxPoints(itemName)
+	var grades []models.Grade
+	highestRaw := 0.0
+	for _, g := range loadRosterGrades(targetSubject) {
+		if g.ItemName == itemName && g.Status == models.GradeScored {
+			grades = append(grades, g)
+			highestRaw = max(highestRaw, g.Score)
+		}
+	}
+
+	// 只有加分需要老師輸入的參數；線性調整的倍率一律依目前的原始分數重新計算，不採用表單上的值
+	param := 0.0
+	switch curveType {
+	case utils.CurveFlat:
+		var err error
+		param, err = strconv.ParseFloat(strings.TrimSpace(c.PostForm("param")), 64)
+		if err != nil || math.IsInf(param, 0) || !(param > 0) {
+			c.String(400, "❌ 加分的分數必須是大於 0 的數字")
+			return
+		}
+	case utils.CurveLinear:
+		param = utils.LinearCurveFactor(highestRaw, maxPoints)
+		if !(param > 0) || math.IsInf(param, 0) {
+			c.String(400, "❌ 無法計算等比例放大的倍率")
+			return
+		}
+	}
+
+	// 原始分數不變，異動紀錄記下的是調分前後學生實際看到的分數
+	audit := newGradeAudit(c, targetSubject, models.HistorySourceCurve)
+	for _, g := range grades {
+		before := models.Grade{Score: scheme.EffectiveScore(itemName, g.Score), Status: g.Status}
+		audit.Change(g.StudentID, itemName, &before, utils.ApplyCurve(curveType, param, g.Score, maxPoints), g.Status)
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "subject"}, {Name: "item_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"curve_type", "curve_param", "updated_at", "deleted_at"}),
+		}).Create(&models.GradeItem{
+			Subject:    targetSubject,
+			ItemName:   itemName,
+			MaxPoints:  utils.DefaultMaxPoints,
+			CurveType:  curveType,
+			CurveParam: param,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return audit.Save(tx)
+	})
+	if err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	redirectBack(c)
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/models"
+	"grade-system/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gradebookRow 成績簿中的一位學生
+type gradebookRow struct {
+	Class     string
+	StudentID string
+	Name      string
+	Email     string          // 已綁定的帳號，未註冊時為空白
+	Grades    []*models.Grade // 與 gradebook.Items 對齊，沒有紀錄時為 nil
+	HasTotal  bool
+	Total     float64
+	Letter    string
+	GPA       float64
+}
+
+// gradebook 全班成績簿：名單 × 評量項目，附總分、等第與 GPA
+type gradebook struct {
+	Items     []string
+	MaxPoints []float64
+	Rows      []gradebookRow
+}
+
+// buildGradebook 依名單順序整理出全班成績簿
+func buildGradebook(subject string) gradebook {
+	scheme := utils.LoadScheme(subject)
+	classGrades := loadRosterGrades(subject)
+	totals := scheme.ClassTotals(classGrades)
+
+	var book gradebook
+	column := make(map[string]int)
+	for _, item := range buildSchemeItemRows(scheme, classGrades) {
+		column[item.ItemName] = len(book.Items)
+		book.Items = append(book.Items, item.ItemName)
+		book.MaxPoints = append(book.MaxPoints, item.MaxPoints)
+	}
+
+	byStudent := make(map[string][]*models.Grade)
+	for i := range classGrades {
+		g := &classGrades[i]
+		if byStudent[g.StudentID] == nil {
+			byStudent[g.StudentID] = make([]*models.Grade, len(book.Items))
+		}
+		byStudent[g.StudentID][column[g.ItemName]] = g
+	}
+
+	var students []models.Student
+	initializers.DB.Where("subject = ?", subject).Find(&students)
+	emails := make(map[string]string, len(students))
+	for _, st := range students {
+		emails[st.StudentID] = st.Email
+	}
+
+	var rosters []models.Roster
+	initializers.DB.Where("subject = ?", subject).Order("class asc, student_id asc").Find(&rosters)
+	for _, r := range rosters {
+		row := gradebookRow{Class: r.Class, StudentID: r.StudentID, Name: r.Name, Email: emails[r.StudentID], Grades: byStudent[r.StudentID]}
+		if row.Grades == nil {
+			row.Grades = make([]*models.Grade, len(book.Items))
+		}
+		if total, ok := totals[r.StudentID]; ok {
+			cut := scheme.Letters.Lookup(total)
+			row.HasTotal, row.Total, row.Letter, row.GPA = true, total, cut.Letter, cut.GPA
+		}
+		book.Rows = append(book.Rows, row)
+	}
+	return book
+}
+
+// Table 轉成與成績匯入相同格式的表格：標題、滿分列，再來每位學生一列；
+// float64 為數字、nil 為空白 (沒有成績紀錄)，未登錄的紀錄寫成「-」，Email / Total / Letter / GPA 欄位在匯入時會被忽略
+func (book gradebook) Table() [][]interface{} {
+	header := []interface{}{"Class", "ID", "Name", "Email"}
+	maxRow := []interface{}{nil, "Max Points", nil, nil}
+	for i, item := range book.Items {
+		header = append(header, item)
+		maxRow = append(maxRow, book.MaxPoints[i])
+	}
+	rows := [][]interface{}{append(header, "Total", "Letter", "GPA"), maxRow}
+
+	for _, r := range book.Rows {
+		row := []interface{}{r.Class, r.StudentID, r.Name, r.Email}
+		for _, g := range r.Grades {
+			switch {
+			case g == nil:
+				row = append(row, nil)
+			case g.Status == models.GradeScored:
+				row = append(row, g.Score)
+			default:
+				row = append(row, utils.GradeStatusToken(g.Status))
+			}
+		}
+		if r.HasTotal {
+			row = append(row, r.Total, r.Letter, r.GPA)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ExportGradebookXLSX 下載全班成績簿 (.xlsx)，格式與成績匯入相同，可修改後直接上傳
+func ExportGradebookXLSX(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	var buf bytes.Buffer
+	if err := utils.WriteXLSX(&buf, targetSubject, buildGradebook(targetSubject).Table()); err != nil {
+		c.String(500, "❌ 匯出失敗")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-gradebook.xlsx"`, targetSubject))
+	c.Data(200, utils.XLSXContentType, buf.Bytes())
+}
+
+// ExportGradebookCSV 下載全班成績簿 (CSV)，可在試算表修改後直接用「上傳成績」匯回
+func ExportGradebookCSV(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	sendCSV(c, targetSubject+"-gradebook.csv", buildGradebook(targetSubject).Table())
+}
+
+// ExportMoodleCSV 下載可用 Moodle「匯入成績 (CSV)」上傳的學期總成績，以 ID number 或 Email 對應學生；
+// 等第與 GPA 各一欄，匯入時可對應到文字型的成績項目或選擇略過
+func ExportMoodleCSV(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	rows := [][]interface{}{{"ID number", "Email address", "Final total", "Letter", "GPA"}}
+	for _, r := range buildGradebook(targetSubject).Rows {
+		if r.HasTotal {
+			rows = append(rows, []interface{}{r.StudentID, r.Email, r.Total, r.Letter, r.GPA})
+		}
+	}
+	sendCSV(c, targetSubject+"-moodle.csv", rows)
+}
+
+// ExportClassroomCSV 下載可匯入 Google Classroom 的學期總成績；Classroom 只認 Email，未綁定帳號的學生不會列出。
+// Classroom 的成績只能是數字分數，匯入時每一欄都會被當成一份作業，因此不附等第與 GPA (請用成績簿匯出)
+func ExportClassroomCSV(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	rows := [][]interface{}{{"Email Address", "Name", "Final Grade"}}
+	for _, r := range buildGradebook(targetSubject).Rows {
+		if r.HasTotal && r.Email != "" {
+			rows = append(rows, []interface{}{r.Email, r.Name, r.Total})
+		}
+	}
+	sendCSV(c, targetSubject+"-classroom.csv", rows)
+}
+
+// csvRecords 將表格轉成 CSV 的文字；float64 寫成數字，nil 為空白
+func csvRecords(rows [][]interface{}) [][]string {
+	records := make([][]string, len(rows))
+	for r, row := range rows {
+		records[r] = make([]string, len(row))
+		for i, v := range row {
+			switch val := v.(type) {
+			case nil:
+			case float64:
+				records[r][i] = strconv.FormatFloat(val, 'f', -1, 64)
+			default:
+				records[r][i] = fmt.Sprint(val)
+			}
+		}
+	}
+	return records
+}
+
+// sendCSV 以 CSV 下載表格
+func sendCSV(c *gin.Context, fileName string, rows [][]interface{}) {
+	var buf bytes.Buffer
+	// 加上 UTF-8 BOM，Excel 開啟時中文才不會變成亂碼；匯入時會自動去掉
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.WriteAll(csvRecords(rows))
+	if err := w.Error(); err != nil {
+		c.String(500, "❌ 匯出失敗")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+}
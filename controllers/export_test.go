@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"grade-system/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// 匯出的成績簿原封不動匯回時不應有任何變動，未評分的儲存格也不會變成「未登錄」紀錄
+func TestGradebookExportRoundTrip(t *testing.T) {
+	existing := []models.Grade{
+		{StudentID: "S001", ItemName: "HW1", Score: 17},
+		{StudentID: "S001", ItemName: "Exam", Status: models.GradeExcused},
+		{StudentID: "S002", ItemName: "HW1", Status: models.GradeMissing},
+		{StudentID: "S002", ItemName: "Exam", Status: models.GradeAbsent},
+		{StudentID: "S003", ItemName: "Exam", Score: 88.5},
+	}
+	book := gradebook{Items: []string{"HW1", "Exam"}, MaxPoints: []float64{20, 100}}
+	for _, r := range []struct{ class, sid, name string }{{"A", "S001", "王小明"}, {"A", "S002", "李小華"}, {"B", "S003", "陳大同"}, {"B", "S004", "林小美"}} {
+		row := gradebookRow{Class: r.class, StudentID: r.sid, Name: r.name, Grades: make([]*models.Grade, len(book.Items))}
+		for i := range existing {
+			g := &existing[i]
+			if g.StudentID != r.sid {
+				continue
+			}
+			for col, item := range book.Items {
+				if item == g.ItemName {
+					row.Grades[col] = g
+				}
+			}
+		}
+		row.HasTotal, row.Total, row.Letter, row.GPA = true, 80, "A-", 3.7
+		book.Rows = append(book.Rows, row)
+	}
+	records := csvRecords(book.Table())
+
+	mock := newMockDB(t)
+	mock.ExpectQuery(`SELECT "student_id" FROM "rosters"`).
+		WillReturnRows(sqlmock.NewRows([]string{"student_id"}).AddRow("S001").AddRow("S002").AddRow("S003").AddRow("S004"))
+	grades := sqlmock.NewRows([]string{"id", "student_id", "item_name", "score", "status", "subject"})
+	for i, g := range existing {
+		grades.AddRow(i+1, g.StudentID, g.ItemName, g.Score, g.Status, "circuit")
+	}
+	mock.ExpectQuery(`SELECT \* FROM "grades"`).WillReturnRows(grades)
+
+	plan, err := parseGradeImport("circuit", records, 1, guessColumnMapping(records[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("匯回後有 %d 筆變動：%+v", len(plan.Changes), plan.Changes)
+	}
+	if len(plan.BadCells) != 0 || len(plan.NewItems) != 0 || len(plan.UnknownIDs) != 0 {
+		t.Errorf("不應有錯誤、新項目或名單外的學號：%+v %v %v", plan.BadCells, plan.NewItems, plan.UnknownIDs)
+	}
+	if plan.MaxPoints["HW1"] != 20 || plan.MaxPoints["Exam"] != 100 {
+		t.Errorf("滿分列讀為 %v", plan.MaxPoints)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// 空白儲存格不變更資料，「-」只會替沒有紀錄的項目補上未登錄
+func TestGradeImportBlankAndMissingMarker(t *testing.T) {
+	mock := newMockDB(t)
+	mock.ExpectQuery(`SELECT "student_id" FROM "rosters"`).
+		WillReturnRows(sqlmock.NewRows([]string{"student_id"}).AddRow("S001").AddRow("S002"))
+	mock.ExpectQuery(`SELECT \* FROM "grades"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "student_id", "item_name", "score", "subject"}).AddRow(1, "S002", "HW1", 15, "circuit"))
+
+	records := [][]string{
+		{"ID", "HW1", "HW2"},
+		{"S001", "", "-"},
+		{"S002", "-", ""},
+	}
+	plan, err := parseGradeImport("circuit", records, 1, guessColumnMapping(records[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].StudentID != "S001" || plan.Changes[0].ItemName != "HW2" || plan.Changes[0].Status != models.GradeMissing {
+		t.Errorf("變動為 %+v，應只有 S001 的 HW2 補上未登錄", plan.Changes)
+	}
+}
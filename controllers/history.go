@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"grade-system/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// historyLimit 異動紀錄頁面最多列出的筆數
+const historyLimit = 500
+
+// gradeAudit 收集同一次操作 (同一位老師、同一種來源) 的成績變動，與成績在同一個交易中寫入
+type gradeAudit struct {
+	Subject   string
+	ChangedBy string
+	Source    string
+	BatchID   *uint
+	entries   []models.GradeHistory
+}
+
+// newGradeAudit 以目前登入的老師建立變動紀錄
+func newGradeAudit(c *gin.Context, subject, source string) *gradeAudit {
+	return &gradeAudit{Subject: subject, ChangedBy: teacherEmail(c), Source: source}
+}
+
+// Change 記錄一筆新增或修改；old 為 nil 代表原本沒有成績
+func (a *gradeAudit) Change(studentID, itemName string, old *models.Grade, score float64, status string) {
+	entry := models.GradeHistory{StudentID: studentID, ItemName: itemName, Action: models.HistoryActionCreate, NewScore: score, NewStatus: status}
+	if old != nil {
+		if old.Score == score && old.Status == status {
+			return
+		}
+		entry.Action = models.HistoryActionUpdate
+		entry.OldScore, entry.OldStatus, entry.OldNeedsReview = old.Score, old.Status, old.NeedsReview
+	}
+	a.add(entry)
+}
+
+// Delete 記錄一筆刪除
+func (a *gradeAudit) Delete(old models.Grade) {
+	a.add(models.GradeHistory{StudentID: old.StudentID, ItemName: old.ItemName, Action: models.HistoryActionDelete, OldScore: old.Score, OldStatus: old.Status, OldNeedsReview: old.NeedsReview})
+}
+
+func (a *gradeAudit) add(entry models.GradeHistory) {
+	entry.Subject, entry.ChangedBy, entry.Source, entry.BatchID = a.Subject, a.ChangedBy, a.Source, a.BatchID
+	a.entries = append(a.entries, entry)
+}
+
+// Save 寫入收集到的紀錄，需與成績變動在同一個交易中呼叫
+func (a *gradeAudit) Save(tx *gorm.DB) error {
+	if len(a.entries) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&a.entries, importBatchSize).Error
+}
+
+// loadGradeHistory 依學號、項目篩選成績異動紀錄，新的在前
+func loadGradeHistory(subject, studentID, itemName string) []models.GradeHistory {
+	query := initializers.DB.Where("subject = ?", subject)
+	if studentID != "" {
+		query = query.Where("student_id = ?", studentID)
+	}
+	if itemName != "" {
+		query = query.Where("item_name = ?", itemName)
+	}
+	var history []models.GradeHistory
+	query.Order("id desc").Limit(historyLimit).Find(&history)
+	return history
+}
+
+// ShowGradeHistory 教師查看單一學生或單一評量項目的成績異動紀錄
+func ShowGradeHistory(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	studentID := strings.TrimSpace(c.Query("student_id"))
+	itemName := strings.TrimSpace(c.Query("item_name"))
+
+	c.HTML(200, "history.html", gin.H{
+		"History":   loadGradeHistory(targetSubject, studentID, itemName),
+		"StudentID": studentID,
+		"ItemName":  itemName,
+		"Limit":     historyLimit,
+		"Subject":   targetSubject,
+		"AppName":   initializers.AppName,
+		"Base":      middleware.CoursePath(c),
+	})
+}
@@ -0,0 +1,465 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"grade-system/models"
+	"grade-system/utils"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gradeCell 一筆要寫入的成績
+type gradeCell struct {
+	StudentID string
+	ItemName  string
+	Score     float64
+	Status    string
+	HasOld    bool
+	Old       float64
+	OldStatus string
+	// OldNeedsReview 原本的成績是否尚待老師確認，記在異動紀錄中供復原使用
+	OldNeedsReview bool
+}
+
+// badCell 無法解析的儲存格
+type badCell struct {
+	Row       int
+	StudentID string
+	ItemName  string
+	Value     string
+}
+
+// gradeImport 成績檔解析後的匯入計畫，預覽與確認寫入共用同一份結果
+type gradeImport struct {
+	Items       []string
+	NewItems    []string
+	MaxPoints   map[string]float64
+	Cells       []gradeCell
+	Changes     []gradeCell
+	Unchanged   int
+	UnknownIDs  []string
+	BadCells    []badCell
+	IgnoredCols []string
+	NeedsReview bool // 助教匯入的成績寫入後要等老師確認
+}
+
+// 預設視為非成績項目的欄位 (包含成績簿匯出時附上的總分與等第)，老師可在欄位對應頁面調整
+var gradeIgnoreCols = map[string]bool{"no.": true, "no": true, "class": true, "id": true, "grade": true, "name": true, "姓名": true, "total": true, "letter": true, "gpa": true, "總分": true, "等第": true, "email": true}
+
+// readUploadedFile 讀出上傳檔案的原始內容
+func readUploadedFile(c *gin.Context, field string) ([]byte, string, error) {
+	file, _ := c.FormFile(field)
+	if file == nil {
+		return nil, "", errors.New("請選擇檔案")
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(f)
+	return raw, file.Filename, err
+}
+
+// decodePayload 還原預覽頁面以隱藏欄位帶回的檔案內容
+func decodePayload(c *gin.Context) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(c.PostForm("payload"))
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("匯入資料遺失，請重新上傳")
+	}
+	return raw, nil
+}
+
+// parseCSV 將檔案內容讀成二維表格；Excel「Unicode 文字」存出的檔案以 Tab 分隔，也一併支援
+func parseCSV(raw []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.FieldsPerRecord = -1
+	firstLine, _, _ := bytes.Cut(raw, []byte("\n"))
+	if bytes.Contains(firstLine, []byte("\t")) && !bytes.Contains(firstLine, []byte(",")) {
+		reader.Comma = '\t'
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.New("CSV 讀取失敗")
+	}
+	if len(records) == 0 {
+		return nil, errors.New("檔案是空的")
+	}
+	return records, nil
+}
+
+// uploadTable 上傳的 CSV 或 .xlsx 讀出的表格
+type uploadTable struct {
+	Raw      []byte
+	FileName string
+	Sheet    string
+	Sheets   []string
+	Encoding string     // CSV 實際使用的文字編碼
+	Records  [][]string // .xlsx 有多個工作表且尚未選擇時為 nil
+	FirstRow int        // Records[0] (標題列) 在檔案中的列號，.xlsx 標題前有空白列時大於 1
+}
+
+// readUploadTable 讀取上傳的 CSV 或 .xlsx；從工作表選擇頁或預覽頁回來時改用隱藏欄位帶回的內容
+func readUploadTable(c *gin.Context, field string) (*uploadTable, error) {
+	table := &uploadTable{Sheet: c.PostForm("sheet"), FirstRow: 1}
+	var err error
+	if c.PostForm("payload") != "" {
+		table.Raw, err = decodePayload(c)
+		table.FileName = c.PostForm("file_name")
+	} else {
+		table.Raw, table.FileName, err = readUploadedFile(c, field)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.IsXLSX(table.Raw) {
+		var text []byte
+		text, table.Encoding, err = utils.DecodeText(table.Raw, c.PostForm("encoding"))
+		if err != nil {
+			return nil, err
+		}
+		table.Records, err = parseCSV(text)
+		return table, err
+	}
+	book, err := utils.OpenXLSX(table.Raw)
+	if err != nil {
+		return nil, err
+	}
+	table.Sheets = book.SheetNames()
+	if table.Sheet == "" && len(table.Sheets) == 1 {
+		table.Sheet = table.Sheets[0]
+	}
+	if table.Sheet == "" {
+		return table, nil
+	}
+	table.Records, table.FirstRow, err = book.ReadSheet(table.Sheet)
+	if err == nil && len(table.Records) == 0 {
+		err = errors.New("工作表是空的")
+	}
+	return table, err
+}
+
+// showSheetSelect 讓老師選擇要匯入 .xlsx 的哪一個工作表，選好後送回原本的上傳網址
+func showSheetSelect(c *gin.Context, subject, action string, table *uploadTable) {
+	c.HTML(200, "sheet_select.html", gin.H{
+		"Action":   action,
+		"FileName": table.FileName,
+		"Sheets":   table.Sheets,
+		"Payload":  base64.StdEncoding.EncodeToString(table.Raw),
+		"Subject":  subject,
+		"AppName":  initializers.AppName,
+		"Base":     middleware.CoursePath(c),
+	})
+}
+
+// loadEmailToID 已綁定帳號的 Email (小寫) 對應到學號，供只有 Email 的外部成績檔使用
+func loadEmailToID(subject string) map[string]string {
+	var students []models.Student
+	initializers.DB.Where("subject = ?", subject).Find(&students)
+	emailToID := make(map[string]string, len(students))
+	for _, st := range students {
+		emailToID[strings.ToLower(st.Email)] = st.StudentID
+	}
+	return emailToID
+}
+
+// normalizeGradeTable 將 Moodle / Google Classroom 的成績檔轉成本系統的格式，回傳偵測到的格式
+func normalizeGradeTable(subject string, table *uploadTable) string {
+	format := utils.DetectGradebookFormat(table.Records)
+	if format != utils.FormatNative {
+		table.Records = utils.NormalizeGradebook(format, table.Records, loadEmailToID(subject), utils.LoadScheme(subject))
+	}
+	return format
+}
+
+// resolveGradeMapping 外部系統的格式已轉成「第一欄學號、其餘皆為成績項目」，不需要再對應欄位
+func resolveGradeMapping(c *gin.Context, subject string, table *uploadTable, format string) (columnMapping, bool, error) {
+	if format != utils.FormatNative {
+		return columnMapping{ID: 0, Name: -1, Class: -1, Ignored: make(map[int]bool)}, true, nil
+	}
+	return resolveColumnMapping(c, subject, models.ImportKindGrades, table.Records[0])
+}
+
+// findGradeIDColumn 依常見的標題名稱找出學號欄位
+func findGradeIDColumn(header []string) int {
+	for i, colName := range header {
+		cleanName := strings.ToLower(utils.CleanHeader(colName))
+		if cleanName == "id" || cleanName == "student id" || cleanName == "student_id" || cleanName == "學號" {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseGradeImport 依欄位對應比對成績檔與資料庫現況，產生匯入計畫 (不寫入資料庫)；firstRow 為標題列在檔案中的列號
+func parseGradeImport(subject string, records [][]string, firstRow int, mapping columnMapping) (*gradeImport, error) {
+	header := records[0]
+	idIndex := mapping.ID
+	if idIndex < 0 || idIndex >= len(header) {
+		return nil, errors.New("找不到 ID 欄位")
+	}
+
+	var validStudentIDs []string
+	initializers.DB.Model(&models.Roster{}).Where("subject = ?", subject).Pluck("student_id", &validStudentIDs)
+	validStudentMap := make(map[string]bool)
+	for _, id := range validStudentIDs {
+		validStudentMap[utils.CleanID(id)] = true
+	}
+
+	var existing []models.Grade
+	initializers.DB.Where("subject = ?", subject).Find(&existing)
+	oldGrades := make(map[string]models.Grade)
+	knownItems := make(map[string]bool)
+	for _, g := range existing {
+		oldGrades[g.StudentID+"\x00"+g.ItemName] = g
+		knownItems[g.ItemName] = true
+	}
+
+	plan := &gradeImport{MaxPoints: make(map[string]float64)}
+	gradeCols := make(map[int]string)
+	for colIdx, colName := range header {
+		name := utils.CleanHeader(colName)
+		if colIdx == idIndex {
+			continue
+		}
+		if name == "" || !mapping.IsItem(colIdx) {
+			plan.IgnoredCols = append(plan.IgnoredCols, name)
+			continue
+		}
+		gradeCols[colIdx] = name
+		plan.Items = append(plan.Items, name)
+		if !knownItems[name] {
+			plan.NewItems = append(plan.NewItems, name)
+		}
+	}
+
+	// 標題下方可選擇性加一列「滿分」(ID 欄填 Max Points)，用來設定各項目的滿分
+	dataStart := 1
+	if len(records) > 1 && len(records[1]) > idIndex && utils.IsMaxPointsLabel(records[1][idIndex]) {
+		for colIdx, cellValue := range records[1] {
+			name, ok := gradeCols[colIdx]
+			if !ok {
+				continue
+			}
+			if maxPoints, err := strconv.ParseFloat(strings.TrimSpace(cellValue), 64); err == nil && maxPoints > 0 {
+				plan.MaxPoints[name] = maxPoints
+			}
+		}
+		dataStart = 2
+	}
+
+	unknown := make(map[string]bool)
+	cellIndex := make(map[string]int)
+	for i, row := range records {
+		if i < dataStart || len(row) <= idIndex {
+			continue
+		}
+		studentID := utils.CleanID(row[idIndex])
+		if studentID == "" {
+			continue
+		}
+		if !validStudentMap[studentID] {
+			if !unknown[studentID] {
+				unknown[studentID] = true
+				plan.UnknownIDs = append(plan.UnknownIDs, studentID)
+			}
+			continue
+		}
+
+		for colIdx, cellValue := range row {
+			name, ok := gradeCols[colIdx]
+			if !ok {
+				continue
+			}
+			// 空白儲存格不變更任何資料，匯出的成績簿原封不動匯回時不會多出「未登錄」紀錄；要標為未登錄請填「-」
+			if strings.TrimSpace(cellValue) == "" {
+				continue
+			}
+			score, status, ok := utils.ParseGradeCell(cellValue)
+			if !ok {
+				plan.BadCells = append(plan.BadCells, badCell{Row: i + firstRow, StudentID: studentID, ItemName: name, Value: strings.TrimSpace(cellValue)})
+				continue
+			}
+
+			// 同一位學生同一項目重複出現時以最後一次為準，避免同一批 upsert 撞到自己
+			key := studentID + "\x00" + name
+			old, hasOld := oldGrades[key]
+			// 「-」只會建立「未登錄」紀錄，不會覆蓋已經有的成績
+			if status == models.GradeMissing && hasOld {
+				continue
+			}
+			cell := gradeCell{StudentID: studentID, ItemName: name, Score: score, Status: status}
+			if hasOld {
+				cell.HasOld, cell.Old, cell.OldStatus, cell.OldNeedsReview = true, old.Score, old.Status, old.NeedsReview
+			}
+			if pos, dup := cellIndex[key]; dup {
+				plan.Cells[pos] = cell
+				continue
+			}
+			cellIndex[key] = len(plan.Cells)
+			plan.Cells = append(plan.Cells, cell)
+		}
+	}
+
+	for _, cell := range plan.Cells {
+		if cell.HasOld && cell.Old == cell.Score && cell.OldStatus == cell.Status {
+			plan.Unchanged++
+		} else {
+			plan.Changes = append(plan.Changes, cell)
+		}
+	}
+	sort.Strings(plan.UnknownIDs)
+	return plan, nil
+}
+
+// importBatchSize 每次 upsert 的筆數，避免單一 SQL 參數過多
+const importBatchSize = 500
+
+// importSummary 匯入完成後回報給老師的統計
+type importSummary struct {
+	Created    int
+	Updated    int
+	Unchanged  int
+	UnknownIDs int
+	BadCells   int
+}
+
+func (s importSummary) String() string {
+	return fmt.Sprintf("✅ 匯入完成：新增 %d 筆、更新 %d 筆、未變更 %d 筆；略過名單外學號 %d 個、無法解析的儲存格 %d 格",
+		s.Created, s.Updated, s.Unchanged, s.UnknownIDs, s.BadCells)
+}
+
+// applyGradeImport 以批次 upsert 寫入滿分設定與有變動的成績，並把每一筆變動記到 batch 與 audit；需在交易中呼叫
+func applyGradeImport(tx *gorm.DB, plan *gradeImport, batch *models.ImportBatch, audit *gradeAudit) (importSummary, error) {
+	summary := importSummary{Unchanged: plan.Unchanged, UnknownIDs: len(plan.UnknownIDs), BadCells: len(plan.BadCells)}
+	subject := batch.Subject
+
+	if len(plan.MaxPoints) > 0 {
+		var existingItems []models.GradeItem
+		tx.Where("subject = ?", subject).Find(&existingItems)
+		oldMax := make(map[string]float64, len(existingItems))
+		for _, item := range existingItems {
+			oldMax[item.ItemName] = item.MaxPoints
+		}
+
+		var items []models.GradeItem
+		var itemHistory []models.GradeItemHistory
+		for name, maxPoints := range plan.MaxPoints {
+			entry := models.GradeItemHistory{BatchID: batch.ID, Subject: subject, ItemName: name, Action: models.HistoryActionCreate}
+			if old, ok := oldMax[name]; ok {
+				if old == maxPoints {
+					continue
+				}
+				entry.Action, entry.OldMaxPoints = models.HistoryActionUpdate, old
+			}
+			items = append(items, models.GradeItem{Subject: subject, ItemName: name, MaxPoints: maxPoints})
+			itemHistory = append(itemHistory, entry)
+		}
+		if len(items) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "subject"}, {Name: "item_name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"max_points", "updated_at", "deleted_at"}),
+			}).CreateInBatches(&items, importBatchSize).Error
+			if err != nil {
+				return summary, err
+			}
+			if err := tx.CreateInBatches(&itemHistory, importBatchSize).Error; err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	if len(plan.Changes) == 0 {
+		return summary, nil
+	}
+	grades := make([]models.Grade, 0, len(plan.Changes))
+	for _, cell := range plan.Changes {
+		if cell.HasOld {
+			summary.Updated++
+			audit.Change(cell.StudentID, cell.ItemName, &models.Grade{Score: cell.Old, Status: cell.OldStatus, NeedsReview: cell.OldNeedsReview}, cell.Score, cell.Status)
+		} else {
+			summary.Created++
+			audit.Change(cell.StudentID, cell.ItemName, nil, cell.Score, cell.Status)
+		}
+		grades = append(grades, models.Grade{StudentID: cell.StudentID, ItemName: cell.ItemName, Score: cell.Score, Status: cell.Status, Subject: subject, NeedsReview: plan.NeedsReview})
+	}
+	// 加入 deleted_at 確保幽靈紀錄可以在這一步復活
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "student_id"}, {Name: "item_name"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"score", "status", "needs_review", "updated_at", "deleted_at"}),
+	}).CreateInBatches(&grades, importBatchSize).Error
+	if err != nil {
+		return summary, err
+	}
+	return summary, audit.Save(tx)
+}
+
+// parseRosterRecords 依欄位對應讀出名單，同一個學號重複出現時以最後一次為準
+func parseRosterRecords(subject string, records [][]string, mapping columnMapping) []models.Roster {
+	var rosters []models.Roster
+	seen := make(map[string]int)
+	for i, row := range records {
+		if i == 0 || len(row) <= mapping.ID {
+			continue
+		}
+		sid := utils.CleanID(row[mapping.ID])
+		if sid == "" {
+			continue
+		}
+
+		class := ""
+		if mapping.Class != -1 && len(row) > mapping.Class {
+			class = strings.TrimSpace(row[mapping.Class])
+		}
+		name := ""
+		if mapping.Name != -1 && len(row) > mapping.Name {
+			name = strings.TrimSpace(row[mapping.Name])
+		}
+
+		r := models.Roster{StudentID: sid, Class: class, Name: name, Subject: subject}
+		if pos, dup := seen[sid]; dup {
+			rosters[pos] = r
+			continue
+		}
+		seen[sid] = len(rosters)
+		rosters = append(rosters, r)
+	}
+	return rosters
+}
+
+// readRosterUpload 讀取名單檔並依欄位對應解析；需要選工作表、指定欄位或發生錯誤時已寫出回應，ok 為 false
+func readRosterUpload(c *gin.Context, subject, action string) (*uploadTable, columnMapping, []models.Roster, bool) {
+	table, err := readUploadTable(c, "roster_file")
+	if err != nil {
+		c.String(400, "❌ "+err.Error())
+		return nil, columnMapping{}, nil, false
+	}
+	if table.Records == nil {
+		showSheetSelect(c, subject, action, table)
+		return nil, columnMapping{}, nil, false
+	}
+
+	mapping, ok, err := resolveColumnMapping(c, subject, models.ImportKindRoster, table.Records[0])
+	if err != nil {
+		c.String(400, "❌ "+err.Error())
+		return nil, columnMapping{}, nil, false
+	}
+	if !ok {
+		showColumnMapping(c, subject, models.ImportKindRoster, action, table)
+		return nil, columnMapping{}, nil, false
+	}
+	return table, mapping, parseRosterRecords(subject, table.Records, mapping), true
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"grade-system/models"
+	"grade-system/utils"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// 欄位對應頁面中每一欄可選的用途
+const (
+	columnItem   = "item"
+	columnID     = "id"
+	columnName   = "name"
+	columnClass  = "class"
+	columnIgnore = "ignore"
+)
+
+// columnMapping 上傳檔案各欄位的用途，欄號從 0 開始，-1 代表沒有這一欄
+type columnMapping struct {
+	ID      int
+	Name    int
+	Class   int
+	Ignored map[int]bool
+}
+
+// mappingSampleRows 欄位對應頁面顯示的範例資料列數
+const mappingSampleRows = 5
+
+// guessColumnMapping 依常見的標題名稱猜測欄位用途，作為欄位對應頁面的預設值
+func guessColumnMapping(header []string) columnMapping {
+	m := columnMapping{ID: findGradeIDColumn(header), Name: -1, Class: -1, Ignored: make(map[int]bool)}
+	for i, colName := range header {
+		if i == m.ID {
+			continue
+		}
+		name := strings.ToLower(utils.CleanHeader(colName))
+		switch {
+		case m.Name == -1 && (name == "name" || name == "姓名"):
+			m.Name = i
+		case m.Class == -1 && (name == "class" || name == "班級"):
+			m.Class = i
+		case name == "" || gradeIgnoreCols[name]:
+			m.Ignored[i] = true
+		}
+	}
+	return m
+}
+
+// Roles 轉成每一欄的用途，供欄位對應頁面預先選取
+func (m columnMapping) Roles(columns int) []string {
+	roles := make([]string, columns)
+	for i := range roles {
+		switch {
+		case i == m.ID:
+			roles[i] = columnID
+		case i == m.Name:
+			roles[i] = columnName
+		case i == m.Class:
+			roles[i] = columnClass
+		case m.Ignored[i]:
+			roles[i] = columnIgnore
+		default:
+			roles[i] = columnItem
+		}
+	}
+	return roles
+}
+
+// IsItem 該欄是否為成績項目
+func (m columnMapping) IsItem(col int) bool {
+	return col != m.ID && col != m.Name && col != m.Class && !m.Ignored[col]
+}
+
+// String 編碼成一行文字，存進資料庫或放在隱藏欄位帶到下一頁
+func (m columnMapping) String() string {
+	cols := make([]int, 0, len(m.Ignored))
+	for col := range m.Ignored {
+		cols = append(cols, col)
+	}
+	sort.Ints(cols)
+	ignored := make([]string, len(cols))
+	for i, col := range cols {
+		ignored[i] = strconv.Itoa(col)
+	}
+	return fmt.Sprintf("id=%d;name=%d;class=%d;ignore=%s", m.ID, m.Name, m.Class, strings.Join(ignored, ","))
+}
+
+// parseColumnMapping 還原 columnMapping.String 的結果，並確認欄號都在標題範圍內
+func parseColumnMapping(text string, columns int) (columnMapping, error) {
+	m := columnMapping{ID: -1, Name: -1, Class: -1, Ignored: make(map[int]bool)}
+	for _, part := range strings.Split(text, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		if key == "ignore" {
+			for _, col := range strings.Split(value, ",") {
+				if n, err := strconv.Atoi(col); err == nil {
+					m.Ignored[n] = true
+				}
+			}
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return m, errors.New("欄位對應格式錯誤")
+		}
+		switch key {
+		case "id":
+			m.ID = n
+		case "name":
+			m.Name = n
+		case "class":
+			m.Class = n
+		}
+	}
+	return m, m.validate(columns)
+}
+
+// mappingFromRoles 由欄位對應頁面送出的每欄用途建立對應
+func mappingFromRoles(roles []string, columns int) (columnMapping, error) {
+	m := columnMapping{ID: -1, Name: -1, Class: -1, Ignored: make(map[int]bool)}
+	if len(roles) != columns {
+		return m, errors.New("欄位對應與檔案不符，請重新上傳")
+	}
+	for i, role := range roles {
+		var target *int
+		switch role {
+		case columnID:
+			target = &m.ID
+		case columnName:
+			target = &m.Name
+		case columnClass:
+			target = &m.Class
+		case columnIgnore:
+			m.Ignored[i] = true
+		}
+		if target == nil {
+			continue
+		}
+		if *target != -1 {
+			return m, errors.New("學號、姓名、班級各只能指定一欄")
+		}
+		*target = i
+	}
+	return m, m.validate(columns)
+}
+
+func (m columnMapping) validate(columns int) error {
+	if m.ID < 0 || m.ID >= columns {
+		return errors.New("請指定學號 (ID) 欄位")
+	}
+	if m.Name >= columns || m.Class >= columns {
+		return errors.New("欄位對應與檔案不符，請重新上傳")
+	}
+	return nil
+}
+
+// headerSignature 以標題列辨識檔案版面，同樣標題的檔案共用一份欄位對應
+func headerSignature(header []string) string {
+	names := make([]string, len(header))
+	for i, h := range header {
+		names[i] = strings.ToLower(utils.CleanHeader(h))
+	}
+	sum := sha1.Sum([]byte(strings.Join(names, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadColumnMapping 讀取科目已儲存的欄位對應
+func loadColumnMapping(subject, kind string, header []string) (columnMapping, bool) {
+	var saved models.ColumnMapping
+	err := initializers.DB.Where("subject = ? AND kind = ? AND signature = ?", subject, kind, headerSignature(header)).First(&saved).Error
+	if err != nil {
+		return columnMapping{}, false
+	}
+	m, err := parseColumnMapping(saved.Mapping, len(header))
+	return m, err == nil
+}
+
+// saveColumnMapping 記住這個版面的欄位對應，下次上傳同樣標題的檔案時直接套用
+func saveColumnMapping(subject, kind string, header []string, m columnMapping) {
+	initializers.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}, {Name: "kind"}, {Name: "signature"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mapping", "updated_at", "deleted_at"}),
+	}).Create(&models.ColumnMapping{Subject: subject, Kind: kind, Signature: headerSignature(header), Mapping: m.String()})
+}
+
+// resolveColumnMapping 決定這次上傳要用的欄位對應，依序為：
+// 預覽頁帶回的對應、欄位對應頁面送出的選擇、已儲存的對應；都沒有時 ok 為 false，需顯示欄位對應頁面
+func resolveColumnMapping(c *gin.Context, subject, kind string, header []string) (columnMapping, bool, error) {
+	if text := c.PostForm("mapping"); text != "" {
+		m, err := parseColumnMapping(text, len(header))
+		return m, err == nil, err
+	}
+	if roles, ok := c.GetPostFormArray("role"); ok {
+		m, err := mappingFromRoles(roles, len(header))
+		if err != nil {
+			return m, false, err
+		}
+		if c.PostForm("save_mapping") == "on" {
+			saveColumnMapping(subject, kind, header, m)
+		}
+		return m, true, nil
+	}
+	if c.PostForm("remap") == "" {
+		if m, ok := loadColumnMapping(subject, kind, header); ok {
+			return m, true, nil
+		}
+	}
+	return columnMapping{}, false, nil
+}
+
+// showColumnMapping 讓老師指定每一欄的用途，送出後回到原本的上傳網址
+func showColumnMapping(c *gin.Context, subject, kind, action string, table *uploadTable) {
+	header := table.Records[0]
+	m, saved := loadColumnMapping(subject, kind, header)
+	if !saved {
+		m = guessColumnMapping(header)
+	}
+	roles := m.Roles(len(header))
+	if kind == models.ImportKindRoster {
+		// 名單只需要學號、姓名、班級，其餘欄位一律忽略
+		for i, role := range roles {
+			if role == columnItem {
+				roles[i] = columnIgnore
+			}
+		}
+	}
+
+	var samples [][]string
+	for _, row := range table.Records[1:] {
+		if len(samples) == mappingSampleRows {
+			break
+		}
+		sample := make([]string, len(header))
+		copy(sample, row)
+		samples = append(samples, sample)
+	}
+
+	c.HTML(200, "column_map.html", gin.H{
+		"Action":   action,
+		"IsRoster": kind == models.ImportKindRoster,
+		"Header":   header,
+		"Roles":    roles,
+		"Samples":  samples,
+		"Saved":    saved,
+		"FileName": table.FileName,
+		"Sheet":    table.Sheet,
+		"Encoding": table.Encoding,
+		"Payload":  base64.StdEncoding.EncodeToString(table.Raw),
+		"Subject":  subject,
+		"AppName":  initializers.AppName,
+		"Base":     middleware.CoursePath(c),
+	})
+}
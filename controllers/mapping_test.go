@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseColumnMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		columns int
+		want    columnMapping
+		wantErr string
+	}{
+		{
+			name:    "完整的對應",
+			text:    "id=1;name=2;class=0;ignore=3,5",
+			columns: 6,
+			want:    columnMapping{ID: 1, Name: 2, Class: 0, Ignored: map[int]bool{3: true, 5: true}},
+		},
+		{
+			name:    "沒有姓名與班級",
+			text:    "id=0;name=-1;class=-1;ignore=",
+			columns: 3,
+			want:    columnMapping{ID: 0, Name: -1, Class: -1, Ignored: map[int]bool{}},
+		},
+		{
+			name:    "略過無法解析的忽略欄號",
+			text:    "id=0;name=-1;class=-1;ignore=2,x,",
+			columns: 3,
+			want:    columnMapping{ID: 0, Name: -1, Class: -1, Ignored: map[int]bool{2: true}},
+		},
+		{name: "欄號不是數字", text: "id=a;name=-1;class=-1;ignore=", columns: 3, wantErr: "欄位對應格式錯誤"},
+		{name: "沒有學號欄", text: "id=-1;name=0;class=-1;ignore=", columns: 3, wantErr: "請指定學號"},
+		{name: "學號欄超出標題", text: "id=3;name=-1;class=-1;ignore=", columns: 3, wantErr: "請指定學號"},
+		{name: "姓名欄超出標題", text: "id=0;name=4;class=-1;ignore=", columns: 3, wantErr: "欄位對應與檔案不符"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseColumnMapping(tt.text, tt.columns)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("錯誤為 %v，應包含「%s」", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("解析為 %+v，應為 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnMappingRoundTrip(t *testing.T) {
+	header := []string{"班級", "學號", "姓名", "Email", "HW1", "總分"}
+	m := guessColumnMapping(header)
+	got, err := parseColumnMapping(m.String(), len(header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("%q 還原為 %+v，應為 %+v", m.String(), got, m)
+	}
+	if roles := got.Roles(len(header)); !reflect.DeepEqual(roles, []string{columnClass, columnID, columnName, columnIgnore, columnItem, columnIgnore}) {
+		t.Errorf("各欄用途為 %v", roles)
+	}
+}
+
+func TestMappingFromRoles(t *testing.T) {
+	if _, err := mappingFromRoles([]string{columnID, columnID, columnItem}, 3); err == nil {
+		t.Error("兩欄都指定為學號時應回傳錯誤")
+	}
+	if _, err := mappingFromRoles([]string{columnID, columnItem}, 3); err == nil {
+		t.Error("欄數與檔案不符時應回傳錯誤")
+	}
+	m, err := mappingFromRoles([]string{columnItem, columnID, columnIgnore}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 1 || !m.Ignored[2] || !m.IsItem(0) {
+		t.Errorf("對應為 %+v", m)
+	}
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"grade-system/models"
+	"grade-system/utils"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 名單同步時，檔案中沒有的學生的處理方式；留空代表不處理
+const (
+	missingWithdraw = "withdraw"
+	missingArchive  = "archive"
+	missingDelete   = "delete"
+)
+
+// rosterChange 名單同步時班級、姓名或狀態有變動的學生
+type rosterChange struct {
+	Old models.Roster
+	New models.Roster
+}
+
+// rosterDiff 上傳的名單與目前名單的差異
+type rosterDiff struct {
+	Added     []models.Roster
+	Changed   []rosterChange
+	Missing   []models.Roster // 仍在課堂上、但檔案中沒有的學生
+	Unchanged int
+}
+
+// diffRoster 比對上傳的名單與資料庫現況 (不寫入資料庫)；已退選或封存的學生再次出現在檔案中時視為變動，同步後恢復修課，
+// 旁聽與未完成的學生保留原本的狀態
+func diffRoster(db *gorm.DB, subject string, rosters []models.Roster) *rosterDiff {
+	var existing []models.Roster
+	db.Where("subject = ?", subject).Order("class ASC, student_id ASC").Find(&existing)
+	current := make(map[string]models.Roster, len(existing))
+	for _, r := range existing {
+		current[r.StudentID] = r
+	}
+
+	diff := &rosterDiff{}
+	inFile := make(map[string]bool, len(rosters))
+	for _, r := range rosters {
+		inFile[r.StudentID] = true
+		old, ok := current[r.StudentID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, r)
+		case !utils.RosterEnrolled(old.Status):
+			diff.Changed = append(diff.Changed, rosterChange{Old: old, New: r})
+		case old.Class != r.Class || old.Name != r.Name:
+			r.Status = old.Status
+			diff.Changed = append(diff.Changed, rosterChange{Old: old, New: r})
+		default:
+			diff.Unchanged++
+		}
+	}
+	for _, r := range existing {
+		if !inFile[r.StudentID] && utils.RosterEnrolled(r.Status) {
+			diff.Missing = append(diff.Missing, r)
+		}
+	}
+	return diff
+}
+
+// rosterHistoryEntry 記下學生在這次匯入前的班級、姓名與狀態
+func rosterHistoryEntry(batch *models.ImportBatch, action string, old models.Roster) models.RosterHistory {
+	return models.RosterHistory{
+		BatchID:   batch.ID,
+		Subject:   batch.Subject,
+		StudentID: old.StudentID,
+		Action:    action,
+		OldClass:  old.Class,
+		OldName:   old.Name,
+		OldStatus: old.Status,
+	}
+}
+
+// applyRosterSync 依差異更新名單，actions 為每位缺少的學生選擇的處理方式；需在交易中呼叫
+func applyRosterSync(tx *gorm.DB, batch *models.ImportBatch, diff *rosterDiff, actions map[string]string, audit *gradeAudit) error {
+	var upsert []models.Roster
+	var history []models.RosterHistory
+	for _, r := range diff.Added {
+		upsert = append(upsert, r)
+		history = append(history, models.RosterHistory{BatchID: batch.ID, Subject: batch.Subject, StudentID: r.StudentID, Action: models.HistoryActionCreate})
+		batch.Created++
+	}
+	for _, ch := range diff.Changed {
+		upsert = append(upsert, ch.New)
+		history = append(history, rosterHistoryEntry(batch, models.HistoryActionUpdate, ch.Old))
+		batch.Updated++
+	}
+
+	statusChanges := make(map[string][]string)
+	var remove []string
+	for _, r := range diff.Missing {
+		switch actions[r.StudentID] {
+		case missingWithdraw:
+			statusChanges[models.RosterWithdrawn] = append(statusChanges[models.RosterWithdrawn], r.StudentID)
+			history = append(history, rosterHistoryEntry(batch, models.HistoryActionUpdate, r))
+		case missingArchive:
+			statusChanges[models.RosterArchived] = append(statusChanges[models.RosterArchived], r.StudentID)
+			history = append(history, rosterHistoryEntry(batch, models.HistoryActionUpdate, r))
+		case missingDelete:
+			remove = append(remove, r.StudentID)
+			history = append(history, rosterHistoryEntry(batch, models.HistoryActionDelete, r))
+		default:
+			continue
+		}
+		batch.Removed++
+	}
+	if len(history) == 0 {
+		return nil
+	}
+
+	if len(upsert) > 0 {
+		// 回收桶中的同一位學生由 upsert 還原，並換上檔案中的資料
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "student_id"}, {Name: "subject"}},
+			DoUpdates: clause.AssignmentColumns([]string{"class", "name", "status", "updated_at", "deleted_at"}),
+		}).CreateInBatches(&upsert, importBatchSize).Error
+		if err != nil {
+			return err
+		}
+	}
+	for status, sids := range statusChanges {
+		for part := range slices.Chunk(sids, importBatchSize) {
+			if err := tx.Model(&models.Roster{}).Where("subject = ? AND student_id IN ?", batch.Subject, part).Update("status", status).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	// 刪除的學生與成績移到回收桶，刪掉的成績記進異動紀錄，復原匯入時才找得回來
+	for part := range slices.Chunk(remove, importBatchSize) {
+		var grades []models.Grade
+		tx.Where("subject = ? AND student_id IN ?", batch.Subject, part).Find(&grades)
+		for _, g := range grades {
+			audit.Delete(g)
+		}
+		if err := tx.Where("subject = ? AND student_id IN ?", batch.Subject, part).Delete(&models.Grade{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("subject = ? AND student_id IN ?", batch.Subject, part).Delete(&models.Roster{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.CreateInBatches(&history, importBatchSize).Error; err != nil {
+		return err
+	}
+	if err := audit.Save(tx); err != nil {
+		return err
+	}
+	return tx.Model(batch).Updates(map[string]interface{}{"created": batch.Created, "updated": batch.Updated, "removed": batch.Removed}).Error
+}
+
+// PreviewRosterSync 比對上傳的名單與目前名單，列出新增、變動與檔案中缺少的學生，由老師決定缺少的學生如何處理
+func PreviewRosterSync(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	table, mapping, rosters, ok := readRosterUpload(c, targetSubject, middleware.CoursePath(c)+"/teacher/roster/sync")
+	if !ok {
+		return
+	}
+	if len(rosters) == 0 {
+		c.String(400, "❌ 檔案中沒有任何學號，同步會讓所有學生被列為缺少，請確認檔案")
+		return
+	}
+
+	c.HTML(200, "roster_sync.html", gin.H{
+		"Diff":     diffRoster(initializers.DB, targetSubject, rosters),
+		"FileName": table.FileName,
+		"Sheet":    table.Sheet,
+		"Encoding": table.Encoding,
+		"Mapping":  mapping.String(),
+		"Payload":  base64.StdEncoding.EncodeToString(table.Raw),
+		"Subject":  targetSubject,
+		"AppName":  initializers.AppName,
+		"Base":     middleware.CoursePath(c),
+	})
+}
+
+// ConfirmRosterSync 套用名單同步，整份記成一個可復原的匯入批次
+func ConfirmRosterSync(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	if c.PostForm("payload") == "" {
+		c.String(400, "❌ 匯入資料遺失，請重新上傳")
+		return
+	}
+	table, err := readUploadTable(c, "")
+	if err != nil {
+		c.String(400, "❌ "+err.Error())
+		return
+	}
+	if table.Records == nil {
+		c.String(400, "❌ 未選擇工作表，請重新上傳")
+		return
+	}
+	mapping, ok, err := resolveColumnMapping(c, targetSubject, models.ImportKindRoster, table.Records[0])
+	if err != nil || !ok {
+		c.String(400, "❌ 欄位對應遺失，請重新上傳")
+		return
+	}
+	rosters := parseRosterRecords(targetSubject, table.Records, mapping)
+	if len(rosters) == 0 {
+		c.String(400, "❌ 檔案中沒有任何學號")
+		return
+	}
+
+	actions := make(map[string]string)
+	ids, choices := c.PostFormArray("missing_id"), c.PostFormArray("missing_action")
+	for i := range min(len(ids), len(choices)) {
+		actions[ids[i]] = choices[i]
+	}
+
+	// 在交易中重新比對，預覽之後名單若有變動以當下為準；預覽時沒列出的缺少學生不處理
+	batch := models.ImportBatch{Subject: targetSubject, Kind: models.ImportKindRoster, FileName: table.FileName, CreatedBy: teacherEmail(c)}
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		audit := newGradeAudit(c, targetSubject, models.HistorySourceRosterSync)
+		audit.BatchID = &batch.ID
+		return applyRosterSync(tx, &batch, diffRoster(tx, targetSubject, rosters), actions, audit)
+	})
+	if err != nil {
+		c.String(500, "❌ 名單同步失敗，資料未變更："+err.Error())
+		return
+	}
+	setFlash(c, fmt.Sprintf("✅ 名單同步完成：新增 %d 人、更新 %d 人、移除 %d 人", batch.Created, batch.Updated, batch.Removed))
+	redirectBack(c)
+}
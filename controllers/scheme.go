@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"grade-system/initializers"
+	"grade-system/models"
+	"grade-system/utils"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchemeItemRow 教師後台「評分方式」表格中的一列
+type SchemeItemRow struct {
+	ItemName   string
+	CategoryID uint
+	MaxPoints  float64
+	Curved     bool
+	CurveLabel string
+}
+
+// buildSchemeItemRows 列出所有出現過的評量項目 (已上傳成績 + 已設定過的項目)
+func buildSchemeItemRows(scheme utils.GradingScheme, grades []models.Grade) []SchemeItemRow {
+	seen := make(map[string]bool)
+	var names []string
+	for _, g := range grades {
+		if !seen[g.ItemName] {
+			seen[g.ItemName] = true
+			names = append(names, g.ItemName)
+		}
+	}
+	for name := range scheme.Items {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	rows := make([]SchemeItemRow, 0, len(names))
+	for _, name := range names {
+		row := SchemeItemRow{ItemName: name, MaxPoints: scheme.MaxPoints(name)}
+		if cat, ok := scheme.CategoryOf(name); ok {
+			row.CategoryID = cat.ID
+		}
+		if item := scheme.Items[name]; item.CurveType != utils.CurveNone {
+			row.Curved = true
+			row.CurveLabel = utils.CurveLabel(item.CurveType, item.CurveParam)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// SaveGradeCategory 新增或修改評分分類 (名稱、權重)
+func SaveGradeCategory(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	name := strings.TrimSpace(c.PostForm("name"))
+	weight, err := strconv.ParseFloat(strings.TrimSpace(c.PostForm("weight")), 64)
+	if name == "" || err != nil || weight < 0 {
+		c.String(400, "❌ 分類名稱或權重格式錯誤")
+		return
+	}
+	sortOrder, _ := strconv.Atoi(c.PostForm("sort_order"))
+	dropLowest, _ := strconv.Atoi(c.PostForm("drop_lowest"))
+	bestOf, _ := strconv.Atoi(c.PostForm("best_of"))
+	if dropLowest < 0 || bestOf < 0 {
+		c.String(400, "❌ 採計規則不可為負數")
+		return
+	}
+
+	missingAsZero := c.PostForm("missing_as_zero") == "on"
+	excuseAbsent := c.PostForm("excuse_absent") == "on"
+
+	if id := c.PostForm("id"); id != "" {
+		initializers.DB.Model(&models.GradeCategory{}).
+			Where("id = ? AND subject = ?", id, targetSubject).
+			Updates(map[string]interface{}{
+				"name": name, "weight": weight, "sort_order": sortOrder, "drop_lowest": dropLowest, "best_of": bestOf,
+				"missing_as_zero": missingAsZero, "excuse_absent": excuseAbsent,
+			})
+	} else {
+		initializers.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "subject"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"weight", "sort_order", "drop_lowest", "best_of", "missing_as_zero", "excuse_absent", "updated_at", "deleted_at"}),
+		}).Create(&models.GradeCategory{
+			Subject: targetSubject, Name: name, Weight: weight, SortOrder: sortOrder, DropLowest: dropLowest, BestOf: bestOf,
+			MissingAsZero: missingAsZero, ExcuseAbsent: excuseAbsent,
+		})
+	}
+	redirectBack(c)
+}
+
+// DeleteGradeCategory 刪除分類，原本歸在此分類的項目改回未分類
+func DeleteGradeCategory(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	id := c.Query("id")
+	if id != "" {
+		initializers.DB.Model(&models.GradeItem{}).Where("subject = ? AND category_id = ?", targetSubject, id).Update("category_id", nil)
+		initializers.DB.Unscoped().Where("id = ? AND subject = ?", id, targetSubject).Delete(&models.GradeCategory{})
+	}
+	redirectBack(c)
+}
+
+// SaveGradeItem 設定單一評量項目的分類與滿分
+func SaveGradeItem(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	itemName := strings.TrimSpace(c.PostForm("item_name"))
+	if itemName == "" {
+		c.String(400, "❌ 缺少評量項目名稱")
+		return
+	}
+
+	maxPoints, err := strconv.ParseFloat(strings.TrimSpace(c.PostForm("max_points")), 64)
+	if err != nil || maxPoints <= 0 {
+		maxPoints = utils.DefaultMaxPoints
+	}
+
+	var categoryID *uint
+	if cid, err := strconv.ParseUint(c.PostForm("category_id"), 10, 64); err == nil && cid > 0 {
+		var cat models.GradeCategory
+		if err := initializers.DB.Where("id = ? AND subject = ?", cid, targetSubject).First(&cat).Error; err != nil {
+			c.String(400, "❌ 找不到此分類")
+			return
+		}
+		categoryID = &cat.ID
+	}
+
+	initializers.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}, {Name: "item_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"category_id", "max_points", "updated_at", "deleted_at"}),
+	}).Create(&models.GradeItem{Subject: targetSubject, ItemName: itemName, CategoryID: categoryID, MaxPoints: maxPoints})
+	redirectBack(c)
+}
+
+// SaveLetterCutoffs 以老師輸入的文字整份取代等第對照表；留空則恢復預設
+func SaveLetterCutoffs(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	scale, err := utils.ParseLetterScale(c.PostForm("cutoffs"))
+	if err != nil {
+		c.String(400, "❌ 等第對照表格式錯誤："+err.Error())
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("subject = ?", targetSubject).Delete(&models.LetterCutoff{}).Error; err != nil {
+			return err
+		}
+		for _, cut := range scale {
+			cut.Subject = targetSubject
+			if err := tx.Create(&cut).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	redirectBack(c)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"grade-system/initializers"
+	"grade-system/models"
+	"grade-system/utils"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loadStaff 列出所有課程人員，依課程代碼分組；全站管理員在空白代碼下
+func loadStaff() map[string][]models.CourseStaff {
+	var staff []models.CourseStaff
+	initializers.DB.Order("subject asc, role asc, email asc").Find(&staff)
+	bySubject := make(map[string][]models.CourseStaff)
+	for _, s := range staff {
+		bySubject[s.Subject] = append(bySubject[s.Subject], s)
+	}
+	return bySubject
+}
+
+// copyCourseStaff 延續課程時沿用原課程的老師與助教
+func copyCourseStaff(tx *gorm.DB, from, to string) error {
+	var staff []models.CourseStaff
+	tx.Where("subject = ?", from).Find(&staff)
+	for _, s := range staff {
+		s.Model, s.Subject = gorm.Model{}, to
+		if err := tx.Create(&s).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveCourseStaff 指派或變更課程人員的角色；subject 留空並選擇管理員時新增全站管理員
+func SaveCourseStaff(c *gin.Context) {
+	staff := models.CourseStaff{
+		Subject: c.PostForm("subject"),
+		Email:   strings.ToLower(strings.TrimSpace(c.PostForm("email"))),
+		Role:    c.PostForm("role"),
+	}
+	if staff.Role == models.RoleTAGrader {
+		staff.Items = strings.Join(utils.SplitItems(c.PostForm("items")), ",")
+	}
+	if !strings.Contains(staff.Email, "@") {
+		c.String(400, "❌ 請輸入正確的 Email")
+		return
+	}
+	if staff.Subject == "" {
+		if staff.Role != models.RoleAdmin {
+			c.String(400, "❌ 全站人員只能是管理員")
+			return
+		}
+	} else {
+		if _, ok := findCourse(staff.Subject); !ok {
+			c.String(404, "❌ 找不到此課程")
+			return
+		}
+		if !slices.Contains(utils.StaffRoles, staff.Role) {
+			c.String(400, "❌ 不支援的角色")
+			return
+		}
+	}
+
+	err := initializers.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}, {Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "items", "updated_at", "deleted_at"}),
+	}).Create(&staff).Error
+	if err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, "✅ 已將 "+staff.Email+" 設為「"+utils.StaffRoleLabel(staff.Role)+"」")
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
+
+// RemoveCourseStaff 移除課程人員；不能移除自己的管理員身分，避免把自己鎖在後台外
+func RemoveCourseStaff(c *gin.Context) {
+	var staff models.CourseStaff
+	if err := initializers.DB.First(&staff, c.PostForm("id")).Error; err != nil {
+		c.String(404, "❌ 找不到此人員")
+		return
+	}
+	if staff.Subject == "" && strings.EqualFold(staff.Email, teacherEmail(c)) {
+		c.String(400, "❌ 不能移除自己的管理員身分")
+		return
+	}
+	if err := initializers.DB.Delete(&staff).Error; err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, "🗑️ 已移除 "+staff.Email)
+	c.Redirect(http.StatusSeeOther, adminHome())
+}
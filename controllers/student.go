@@ -1,17 +1,18 @@
 package controllers
 
 import (
-	"fmt"
+	"grade-system/auth"
 	"grade-system/initializers"
+	"grade-system/middleware"
 	"grade-system/models"
 	"grade-system/utils"
-	"math"
 	"net/http"
-	"sort"
+	"slices"
 	"strings"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // 定義要排除的非成績欄位 (黑名單)
@@ -25,123 +26,113 @@ var IgnoredGradeItems = []string{
 }
 
 func ShowIndex(c *gin.Context) {
-	session := sessions.Default(c)
-	uid := session.Get("user_id")
-
+	// APP_MODE=admin 的部署首頁就是總管理後台
 	if initializers.IsAdminMode {
-		if uid == nil {
-			c.HTML(http.StatusOK, "index.html", gin.H{"Logged": false, "AppName": initializers.AppName, "IsAdminMode": true})
-			return
-		}
-		var subjects []string
-		initializers.DB.Model(&models.Grade{}).Distinct("subject").Pluck("subject", &subjects)
-
-		knownSubjects := []string{"circuit", "antenna"}
-		subjectMap := make(map[string]bool)
-		for _, s := range subjects {
-			subjectMap[s] = true
-		}
-		for _, s := range knownSubjects {
-			subjectMap[s] = true
-		}
-
-		var finalSubjects []string
-		for s := range subjectMap {
-			finalSubjects = append(finalSubjects, s)
-		}
-		sort.Strings(finalSubjects)
-
-		userEmail := ""
-		if uStr, ok := uid.(string); ok {
-			userEmail = strings.TrimPrefix(uStr, "ADMIN_")
-		}
-
-		c.HTML(http.StatusOK, "admin_dashboard.html", gin.H{
-			"Subjects":  finalSubjects,
-			"AppName":   initializers.AppName,
-			"UserEmail": userEmail,
-		})
+		ShowAdminDashboard(c)
 		return
 	}
 
-	if uid == nil {
-		c.HTML(http.StatusOK, "index.html", gin.H{"Logged": false, "AppName": initializers.AppName})
+	base := middleware.CoursePath(c)
+	if sessions.Default(c).Get(middleware.AccountSessionKey) == nil {
+		c.HTML(http.StatusOK, "index.html", gin.H{"Logged": false, "Base": base, "AppName": initializers.AppName, "LoginName": auth.Current.Name()})
 		return
 	}
-
-	var s models.Student
-	if err := initializers.DB.Scopes(utils.FilterSubject).First(&s, uid).Error; err != nil {
+	account, ok := currentAccount(c)
+	if !ok {
 		c.Redirect(http.StatusSeeOther, "/logout")
 		return
 	}
 
+	// 網址指定的課程尚未綁定時，首頁提供綁定學號的入口；其他課程的成績照常列出
+	subject := middleware.CourseCode(c)
+	s := models.Student{Name: account.Name, Email: account.Email}
+	bound := subject == ""
+	if !bound {
+		s, bound = findEnrollment(account, subject)
+		if !bound {
+			s = models.Student{Name: account.Name, Email: account.Email}
+		}
+	}
+
 	c.HTML(http.StatusOK, "index.html", gin.H{
-		"Logged":    true,
-		"User":      s,
-		"IsTeacher": utils.IsTeacher(s.Email),
+		"Logged":      true,
+		"User":        s,
+		"Bound":       bound,
+		"Enrollments": loadEnrollments(account.Email),
+		"IsTeacher":   utils.CourseRole(account.Email, subject) != "",
+		"Base":        base,
+		"AppName":     initializers.AppName,
+	})
+}
+
+// ShowAdminDashboard 總管理後台：所有課程與學期；未登入時顯示老師登入頁
+func ShowAdminDashboard(c *gin.Context) {
+	uid := sessions.Default(c).Get("user_id")
+	uStr, ok := uid.(string)
+	if !ok || !strings.HasPrefix(uStr, "ADMIN_") {
+		c.HTML(http.StatusOK, "index.html", gin.H{"Logged": false, "AppName": "教師總管理後台", "IsAdminMode": true, "LoginName": auth.Current.Name()})
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin_dashboard.html", gin.H{
+		"Courses":   loadCourses(),
+		"Terms":     loadTerms(),
+		"Staff":     loadStaff(),
+		"Roles":     utils.StaffRoles,
+		"LocalAuth": auth.IsLocal(),
 		"AppName":   initializers.AppName,
+		"UserEmail": strings.TrimPrefix(uStr, "ADMIN_"),
+		"Flashes":   popFlashes(c),
 	})
 }
 
 func ShowRegister(c *gin.Context) {
-	if initializers.IsAdminMode {
+	if initializers.IsAdminMode || middleware.CourseCode(c) == "" {
 		c.Redirect(302, "/")
 		return
 	}
-	session := sessions.Default(c)
-	email := session.Get("temp_email")
-	if email == nil {
-		c.Redirect(302, "/")
+	account, ok := currentAccount(c)
+	if !ok {
+		c.Redirect(302, middleware.CoursePath(c)+"/")
+		return
+	}
+	if _, bound := findEnrollment(account, middleware.CourseCode(c)); bound {
+		c.Redirect(302, middleware.CoursePath(c)+"/")
 		return
 	}
-	c.HTML(200, "register.html", gin.H{"Email": email})
+	suggested, _ := suggestEnrollment(account, middleware.CourseCode(c))
+	c.HTML(200, "register.html", gin.H{"Email": account.Email, "SuggestedID": suggested.StudentID, "Base": middleware.CoursePath(c)})
 }
 
+// Register 以學號綁定網址指定的課程；同一個帳號在每門課程各綁定一次
 func Register(c *gin.Context) {
-	session := sessions.Default(c)
-	email := session.Get("temp_email")
-	if email == nil {
-		c.Redirect(302, "/")
+	subject, base := middleware.CourseCode(c), middleware.CoursePath(c)
+	account, ok := currentAccount(c)
+	if !ok || subject == "" {
+		c.Redirect(302, base+"/")
 		return
 	}
-	userEmail := email.(string)
-	userName := ""
-	if googleName := session.Get("temp_name"); googleName != nil {
-		userName = googleName.(string)
+	if _, bound := findEnrollment(account, subject); bound {
+		c.Redirect(302, base+"/")
+		return
 	}
 
 	inputID := strings.TrimSpace(c.PostForm("student_id"))
 
 	var roster models.Roster
-	if err := initializers.DB.Where("student_id = ? AND subject = ?", inputID, initializers.CurrentSubject).First(&roster).Error; err != nil {
+	if err := initializers.DB.Scopes(utils.FilterSubject(subject)).Where("student_id = ?", inputID).First(&roster).Error; err != nil {
 		c.String(400, "❌ 驗證失敗：此學號不在名單中，請檢查輸入。")
 		return
 	}
 
-	var existStudent models.Student
-	if err := initializers.DB.Scopes(utils.FilterSubject).Where("student_id = ?", inputID).First(&existStudent).Error; err == nil {
+	if _, err := bindStudent(account, roster); err == errStudentIDTaken {
 		c.String(400, "❌ 綁定失敗：此學號已經被註冊過了！")
 		return
-	}
-
-	newStudent := models.Student{
-		Email:     userEmail,
-		Name:      userName,
-		StudentID: roster.StudentID,
-		Class:     roster.Class,
-		Subject:   initializers.CurrentSubject,
-	}
-
-	if err := initializers.DB.Create(&newStudent).Error; err != nil {
+	} else if err != nil {
 		c.String(500, "資料庫寫入失敗")
 		return
 	}
-
-	session.Set("user_id", newStudent.ID)
-	session.Delete("temp_email")
-	session.Delete("temp_name")
-	session.Save()
-	c.Redirect(302, "/")
+	c.Redirect(302, base+"/")
 }
 
 func ShowMyGrades(c *gin.Context) {
@@ -150,146 +141,108 @@ func ShowMyGrades(c *gin.Context) {
 		return
 	}
 
-	session := sessions.Default(c)
-	uid := session.Get("user_id")
-	if uid == nil {
+	account, ok := currentAccount(c)
+	if !ok {
 		c.Redirect(302, "/")
 		return
 	}
 
-	var s models.Student
-	initializers.DB.Scopes(utils.FilterSubject).First(&s, uid)
+	subject := middleware.CourseCode(c)
+	if subject == "" {
+		c.Redirect(302, "/")
+		return
+	}
+	s, bound := findEnrollment(account, subject)
+	if !bound {
+		c.Redirect(302, middleware.CoursePath(c)+"/register")
+		return
+	}
+	course, _ := findCourse(subject)
 
 	var globalGradeCount int64
-	initializers.DB.Model(&models.Grade{}).Where("subject = ?", initializers.CurrentSubject).Count(&globalGradeCount)
+	initializers.DB.Model(&models.Grade{}).Where("subject = ? AND needs_review = ?", subject, false).Count(&globalGradeCount)
 
 	if globalGradeCount == 0 {
-		c.HTML(http.StatusOK, "no_grades.html", gin.H{"User": s, "AppName": initializers.AppName, "Subject": initializers.CurrentSubject})
+		c.HTML(http.StatusOK, "no_grades.html", gin.H{"User": s, "AppName": initializers.AppName, "Subject": subject, "Base": middleware.CoursePath(c)})
 		return
 	}
 
-	var displayGrades []models.Grade
-	initializers.DB.Scopes(utils.FilterSubject).
+	var myGrades []models.Grade
+	initializers.DB.Where("subject = ?", subject).
 		Where("student_id = ?", s.StudentID).
 		Where("item_name NOT IN ?", IgnoredGradeItems).
 		Order("id asc").
-		Find(&displayGrades)
-
-	preFinalTotal := 0.0
-	finalIdx := -1
-	for i, g := range displayGrades {
-		if strings.EqualFold(g.ItemName, "Final") || strings.EqualFold(g.ItemName, "期末考") {
-			finalIdx = i
-		} else {
-			preFinalTotal += g.Score
-		}
-	}
-
-	if finalIdx != -1 {
-		fWeight := 100.0 - preFinalTotal
-		if fWeight < 0 { fWeight = 0 }
-		rawFinal := displayGrades[finalIdx].Score
-		weightedFinal := rawFinal * (fWeight / 100.0)
-
-		displayGrades[finalIdx].ItemName = fmt.Sprintf("%s (原始:%g, 佔比:%.1f%%)", displayGrades[finalIdx].ItemName, rawFinal, fWeight)
-		displayGrades[finalIdx].Score = math.Round(weightedFinal*100) / 100
-	}
-
-	var allClassGrades []models.Grade
-	initializers.DB.Table("grades").
-		Select("grades.*").
-		Joins("JOIN rosters ON rosters.student_id = grades.student_id AND rosters.subject = grades.subject AND rosters.deleted_at IS NULL").
-		Where("grades.subject = ?", initializers.CurrentSubject).
-		Where("grades.item_name NOT IN ?", IgnoredGradeItems).
-		Where("grades.deleted_at IS NULL").
-		Find(&allClassGrades)
+		Find(&myGrades)
 
-	studentPreFinal := make(map[string]float64)
-	studentFinalRaw := make(map[string]float64)
-	studentHasFinal := make(map[string]bool)
-
-	for _, g := range allClassGrades {
-		if strings.EqualFold(g.ItemName, "Final") || strings.EqualFold(g.ItemName, "期末考") {
-			studentFinalRaw[g.StudentID] = g.Score
-			studentHasFinal[g.StudentID] = true
-		} else {
-			studentPreFinal[g.StudentID] += g.Score
-		}
-	}
-
-	var classTotals []float64
-	myTotal := 0.0
-	studentIDsMap := make(map[string]bool)
-	for _, g := range allClassGrades { studentIDsMap[g.StudentID] = true }
-
-	for sid := range studentIDsMap {
-		pre := studentPreFinal[sid]
-		total := pre
-		if studentHasFinal[sid] {
-			weight := 100.0 - pre
-			if weight < 0 { weight = 0 }
-			total += studentFinalRaw[sid] * (weight / 100.0)
+	// 助教登錄、尚待老師確認的成績只列出項目名稱，不顯示分數也不計入總分與全班統計
+	var pending []string
+	myGrades = slices.DeleteFunc(myGrades, func(g models.Grade) bool {
+		if g.NeedsReview {
+			pending = append(pending, g.ItemName)
 		}
-		total = math.Round(total*100) / 100
-		classTotals = append(classTotals, total)
-		if sid == s.StudentID { myTotal = total }
-	}
-
-	sum, minScore, maxScore := 0.0, 1000.0, -1.0
-	for _, t := range classTotals {
-		sum += t
-		if t < minScore { minScore = t }
-		if t > maxScore { maxScore = t }
-	}
-	if len(classTotals) == 0 { minScore, maxScore = 0, 0 }
-
-	mean := 0.0
-	if len(classTotals) > 0 { mean = sum / float64(len(classTotals)) }
-
-	varianceSum := 0.0
-	for _, t := range classTotals { varianceSum += math.Pow(t-mean, 2) }
-	stdDev := 0.0
-	if len(classTotals) > 0 { stdDev = math.Sqrt(varianceSum / float64(len(classTotals))) }
-
-	sort.Float64s(classTotals)
-	rank := 0
-	for i, t := range classTotals {
-		if t >= myTotal {
-			rank = i
-			break
-		}
-		rank = i + 1
-	}
+		return g.NeedsReview
+	})
+	history := slices.DeleteFunc(loadGradeHistory(subject, s.StudentID, ""), func(h models.GradeHistory) bool {
+		return slices.Contains(pending, h.ItemName)
+	})
 
-	percentile := 0
-	if len(classTotals) > 1 {
-		p := math.Floor((float64(rank) / float64(len(classTotals))) * 100)
-		percentile = int(p)
-		if percentile > 99 { percentile = 99 }
-	} else {
-		percentile = 99
-	}
+	// 學生個人與全班統計共用同一份評分方式
+	scheme := utils.LoadScheme(subject)
+	myEval := scheme.Evaluate(myGrades)
 
-	var top3 []float64
-	count := len(classTotals)
-	for i := count - 1; i >= 0 && len(top3) < 3; i-- {
-		top3 = append(top3, classTotals[i])
-	}
+	// 全班統計只算修課中的學生；退選、旁聽等學生仍看得到自己的成績與總分
+	classGrades := slices.DeleteFunc(loadClassGrades(subject), func(g models.Grade) bool { return g.NeedsReview })
+	classTotals := scheme.ClassTotals(classGrades)
+	myTotal := myEval.Total
+	stats := utils.ComputeClassStats(classTotals)
 
-	finalWeight := 100.0 - preFinalTotal
-	if finalWeight < 0 { finalWeight = 0 }
+	var roster models.Roster
+	initializers.DB.Where("student_id = ? AND subject = ?", s.StudentID, subject).First(&roster)
 
 	c.HTML(200, "my_grades.html", gin.H{
 		"User":        s,
-		"Grades":      displayGrades,
+		"Grades":      myEval.Items,
 		"MyTotal":     myTotal,
-		"ClassMean":   mean,
-		"ClassStdDev": stdDev,
-		"ClassMin":    minScore,
-		"ClassMax":    maxScore,
-		"Percentile":  percentile,
-		"Top3":        top3,
-		"FinalWeight": finalWeight,
+		"ClassMean":   stats.Mean,
+		"ClassStdDev": stats.StdDev,
+		"ClassMin":    stats.Min,
+		"ClassMax":    stats.Max,
+		"Percentile":  stats.Percentile(myTotal),
+		"Top3":        stats.Top3,
+		"Letter":      scheme.Letters.Lookup(myTotal),
+		"FinalWeight": myEval.RemainingWeight,
+		"UseScheme":   scheme.Configured(),
+		"RosterState": roster.Status,
+		"Course":      course,
+		"Courses":     enrolledCourses(account.Email),
+		"ReadOnly":    utils.CourseReadOnly(course),
+		"Pending":     pending,
+		"History":     history,
+		"Base":        middleware.CoursePath(c),
 		"AppName":     initializers.AppName,
 	})
-}
\ No newline at end of file
+}
+
+// loadClassGrades 讀取名單內修課中學生的所有成績，供全班統計使用；退選、旁聽等學生不列入
+func loadClassGrades(subject string) []models.Grade {
+	var grades []models.Grade
+	rosterGradesQuery(subject).Where("rosters.status = ?", models.RosterActive).Find(&grades)
+	return grades
+}
+
+// loadRosterGrades 讀取名單內所有學生 (不論名單狀態) 的成績，供個別學生的總分與成績簿使用
+func loadRosterGrades(subject string) []models.Grade {
+	var grades []models.Grade
+	rosterGradesQuery(subject).Find(&grades)
+	return grades
+}
+
+func rosterGradesQuery(subject string) *gorm.DB {
+	return initializers.DB.Table("grades").
+		Select("grades.*").
+		Joins("JOIN rosters ON rosters.student_id = grades.student_id AND rosters.subject = grades.subject AND rosters.deleted_at IS NULL").
+		Where("grades.subject = ?", subject).
+		Where("grades.item_name NOT IN ?", IgnoredGradeItems).
+		Where("grades.deleted_at IS NULL").
+		Order("grades.id asc")
+}
@@ -1,28 +1,34 @@
 package controllers
 
 import (
-	"encoding/csv"
+	"encoding/base64"
+	"fmt"
 	"grade-system/initializers"
+	"grade-system/middleware"
 	"grade-system/models"
 	"grade-system/utils"
 	// "log"
 	"net/http"
-	"strconv"
+	"slices"
 	"strings"
 
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 // TeacherDashboard 顯示管理介面
 func TeacherDashboard(c *gin.Context) {
-	targetSubject := initializers.CurrentSubject
-	if initializers.IsAdminMode {
-		targetSubject = c.Query("subject")
-		if targetSubject == "" {
-			c.Redirect(302, "/")
-			return
-		}
+	targetSubject := getTargetSubject(c)
+	if targetSubject == "" {
+		c.Redirect(302, "/")
+		return
+	}
+	course, ok := findCourse(targetSubject)
+	if !ok {
+		c.String(404, "❌ 找不到課程「"+targetSubject+"」，請先在總管理後台建立")
+		return
 	}
 
 	var allGrades []models.Grade
@@ -32,144 +38,207 @@ func TeacherDashboard(c *gin.Context) {
 		Class     string
 		StudentID string
 		Name      string
+		Status    string
 		Email     string
+		HasTotal  bool
+		Total     float64
+		Letter    string
 	}
 	var rosterRows []RosterRow
 
 	// 🌟 修正：確保 Join 的時候有比對 subject，且排除幽靈紀錄
 	initializers.DB.Table("rosters").
-		Select("rosters.class, rosters.student_id, rosters.name, students.email").
+		Select("rosters.class, rosters.student_id, rosters.name, rosters.status, students.email").
 		Joins("LEFT JOIN students ON students.student_id = rosters.student_id AND students.subject = rosters.subject AND students.deleted_at IS NULL").
 		Where("rosters.subject = ?", targetSubject).
 		Where("rosters.deleted_at IS NULL").
 		Order("rosters.class ASC, rosters.student_id ASC").
 		Scan(&rosterRows)
 
+	scheme := utils.LoadScheme(targetSubject)
+	totalWeight := 0.0
+	for _, cat := range scheme.Categories {
+		totalWeight += cat.Weight
+	}
+
+	// 全班統計一律以得分率計算，滿分不同的項目才能互相比較；只有修課中的學生列入統計，總分則每位學生都列出
+	classGrades := loadClassGrades(targetSubject)
+	classTotals := scheme.ClassTotals(classGrades)
+	rosterTotals := scheme.ClassTotals(loadRosterGrades(targetSubject))
+	for i := range rosterRows {
+		if total, ok := rosterTotals[rosterRows[i].StudentID]; ok {
+			rosterRows[i].HasTotal = true
+			rosterRows[i].Total = total
+			rosterRows[i].Letter = scheme.Letters.Lookup(total).Letter
+		}
+	}
+
+	itemMax := make(map[string]float64)
+	reviewCount := 0
+	for _, g := range allGrades {
+		itemMax[g.ItemName] = scheme.MaxPoints(g.ItemName)
+		if g.NeedsReview {
+			reviewCount++
+		}
+	}
+
 	c.HTML(200, "teacher.html", gin.H{
-		"AllGrades":  allGrades,
-		"RosterList": rosterRows,
-		"Subject":    targetSubject,
-		"AppName":    initializers.AppName,
-		"IsAdmin":    initializers.IsAdminMode,
+		"AllGrades":     allGrades,
+		"RosterList":    rosterRows,
+		"Categories":    scheme.Categories,
+		"SchemeItems":   buildSchemeItemRows(scheme, allGrades),
+		"TotalWeight":   totalWeight,
+		"ItemMax":       itemMax,
+		"Scheme":        scheme,
+		"ItemStats":     scheme.ItemStatistics(classGrades),
+		"ClassStats":    utils.ComputeClassStats(classTotals),
+		"LetterDist":    scheme.Letters.Distribution(classTotals),
+		"LetterText":    scheme.Letters.String(),
+		"CustomLetters": scheme.CustomLetters,
+		"RecentBatches": loadRecentBatches(targetSubject),
+		"Course":        course,
+		"ReadOnly":      utils.CourseReadOnly(course),
+		"RosterStates":  utils.RosterStatuses,
+		"ReviewCount":   reviewCount,
+		"TrashCount":    countTrash(targetSubject),
+		"RetentionDays": initializers.TrashRetentionDays,
+		"Subject":       targetSubject,
+		"AppName":       initializers.AppName,
+		"Base":          middleware.CoursePath(c),
+		"IsAdmin":       c.GetBool(middleware.AdminSessionKey),
+		"Perm":          middleware.Staff(c),
+		"Flashes":       popFlashes(c),
 	})
 }
 
-// UploadGrades 處理成績 CSV
+// UploadGrades 解析成績 CSV 並顯示預覽，確認後才由 ConfirmGradeUpload 寫入
 func UploadGrades(c *gin.Context) {
-	targetSubject := initializers.CurrentSubject
-	if initializers.IsAdminMode {
-		targetSubject = c.PostForm("subject")
-	}
+	targetSubject := getTargetSubject(c)
 
-	file, _ := c.FormFile("csv_file")
-	if file == nil {
-		c.String(400, "❌ 請選擇檔案")
+	table, err := readUploadTable(c, "csv_file")
+	if err != nil {
+		c.String(400, "❌ "+err.Error())
 		return
 	}
-	f, _ := file.Open()
-	defer f.Close()
-
-	reader := csv.NewReader(f)
-	reader.FieldsPerRecord = -1
-	records, err := reader.ReadAll()
+	if table.Records == nil {
+		showSheetSelect(c, targetSubject, middleware.CoursePath(c)+"/teacher/upload", table)
+		return
+	}
+	format := normalizeGradeTable(targetSubject, table)
+	mapping, ok, err := resolveGradeMapping(c, targetSubject, table, format)
 	if err != nil {
-		c.String(400, "CSV 讀取失敗")
+		c.String(400, "❌ "+err.Error())
 		return
 	}
-
-	var validStudentIDs []string
-	initializers.DB.Model(&models.Roster{}).Where("subject = ?", targetSubject).Pluck("student_id", &validStudentIDs)
-	validStudentMap := make(map[string]bool)
-	for _, id := range validStudentIDs {
-		validStudentMap[utils.CleanID(id)] = true
+	if !ok {
+		showColumnMapping(c, targetSubject, models.ImportKindGrades, middleware.CoursePath(c)+"/teacher/upload", table)
+		return
 	}
-
-	header := records[0]
-	idIndex := -1
-	for i, colName := range header {
-		cleanName := strings.ToLower(utils.CleanHeader(colName))
-		if cleanName == "id" || cleanName == "student id" || cleanName == "student_id" || cleanName == "學號" {
-			idIndex = i
-			break
-		}
+	plan, err := parseGradeImport(targetSubject, table.Records, table.FirstRow, mapping)
+	if err != nil {
+		c.String(400, "❌ "+err.Error())
+		return
 	}
-
-	if idIndex == -1 {
-		c.String(400, "❌ 找不到 ID 欄位")
+	if !requireAssignedItems(c, targetSubject, plan.Items) {
 		return
 	}
 
-	// 🌟 修正：忽略欄位加入 "name" 和 "姓名"，防止變成成績項目！
-	ignoreCols := map[string]bool{"no.": true, "no": true, "class": true, "id": true, "grade": true, "name": true, "姓名": true}
-
-	for i, row := range records {
-		if i == 0 || len(row) <= idIndex { continue }
-		studentID := utils.CleanID(row[idIndex])
-		if studentID == "" || !validStudentMap[studentID] { continue }
+	c.HTML(200, "grade_preview.html", gin.H{
+		"Plan":     plan,
+		"FileName": table.FileName,
+		"Sheet":    table.Sheet,
+		"Encoding": table.Encoding,
+		"Mapping":  mapping.String(),
+		"Format":   format,
+		"Payload":  base64.StdEncoding.EncodeToString(table.Raw),
+		"Subject":  targetSubject,
+		"AppName":  initializers.AppName,
+		"Base":     middleware.CoursePath(c),
+	})
+}
 
-		for colIdx, cellValue := range row {
-			colName := utils.CleanHeader(header[colIdx])
-			if ignoreCols[strings.ToLower(colName)] { continue }
+// ConfirmGradeUpload 重新解析預覽時帶回的檔案，並在同一個交易中寫入
+func ConfirmGradeUpload(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
 
-			score, _ := strconv.ParseFloat(strings.TrimSpace(cellValue), 64)
-			// 加入 deleted_at 確保幽靈紀錄可以在這一步復活
-			initializers.DB.Clauses(clause.OnConflict{
-				Columns:   []clause.Column{{Name: "student_id"}, {Name: "item_name"}, {Name: "subject"}},
-				DoUpdates: clause.AssignmentColumns([]string{"score", "updated_at", "deleted_at"}),
-			}).Create(&models.Grade{
-				StudentID: studentID,
-				ItemName:  colName,
-				Score:     score,
-				Subject:   targetSubject,
-			})
+	if c.PostForm("payload") == "" {
+		c.String(400, "❌ 匯入資料遺失，請重新上傳")
+		return
+	}
+	table, err := readUploadTable(c, "")
+	if err != nil {
+		c.String(400, "❌ "+err.Error())
+		return
+	}
+	if table.Records == nil {
+		c.String(400, "❌ 未選擇工作表，請重新上傳")
+		return
+	}
+	format := normalizeGradeTable(targetSubject, table)
+	mapping, ok, err := resolveGradeMapping(c, targetSubject, table, format)
+	if err != nil || !ok {
+		c.String(400, "❌ 欄位對應遺失，請重新上傳")
+		return
+	}
+	plan, err := parseGradeImport(targetSubject, table.Records, table.FirstRow, mapping)
+	if err != nil {
+		c.String(400, "❌ "+err.Error())
+		return
+	}
+	if !requireAssignedItems(c, targetSubject, plan.Items) {
+		return
+	}
+	plan.NeedsReview = middleware.Staff(c).Review
+
+	// 整份檔案已在 parseGradeImport 驗證完畢，這裡一次寫入；任何一批失敗就整份復原
+	batch := models.ImportBatch{Subject: targetSubject, Kind: models.ImportKindGrades, FileName: table.FileName, CreatedBy: teacherEmail(c)}
+	var summary importSummary
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
 		}
+		audit := newGradeAudit(c, targetSubject, models.HistorySourceCSV)
+		audit.BatchID = &batch.ID
+		summary, err = applyGradeImport(tx, plan, &batch, audit)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&batch).Updates(map[string]interface{}{"created": summary.Created, "updated": summary.Updated}).Error
+	})
+	if err != nil {
+		c.String(500, "❌ 匯入失敗，資料未變更："+err.Error())
+		return
 	}
-	redirectBack(c, targetSubject)
+	setFlash(c, summary.String())
+	redirectBack(c)
 }
 
-// UploadRoster 處理名單 CSV
+// UploadRoster 處理名單 CSV，每次上傳記成一個可復原的匯入批次
 func UploadRoster(c *gin.Context) {
-	targetSubject := initializers.CurrentSubject
-	if initializers.IsAdminMode {
-		targetSubject = c.PostForm("subject")
-	}
-
-	file, _ := c.FormFile("roster_file")
-	f, _ := file.Open()
-	defer f.Close()
-
-	reader := csv.NewReader(f)
-	records, _ := reader.ReadAll()
-
-	classIndex, idIndex, nameIndex := -1, -1, -1
-	for i, col := range records[0] {
-		cName := strings.ToLower(utils.CleanHeader(col))
-		if cName == "class" || cName == "班級" { classIndex = i }
-		if cName == "id" || cName == "學號" { idIndex = i }
-		if cName == "name" || cName == "姓名" { nameIndex = i }
-	}
-
-	for i, row := range records {
-		if i == 0 || len(row) <= idIndex { continue }
-		sid := utils.CleanID(row[idIndex])
-		if sid == "" { continue }
-		
-		class := ""
-		if classIndex != -1 && len(row) > classIndex {
-			class = strings.TrimSpace(row[classIndex])
-		}
-		
-		name := ""
-		if nameIndex != -1 && len(row) > nameIndex {
-			name = strings.TrimSpace(row[nameIndex])
-		}
+	targetSubject := getTargetSubject(c)
 
-		initializers.DB.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "student_id"}, {Name: "subject"}},
-			DoUpdates: clause.AssignmentColumns([]string{"class", "name", "updated_at", "deleted_at"}),
-		}).Create(&models.Roster{StudentID: sid, Class: class, Name: name, Subject: targetSubject})
+	table, _, rosters, ok := readRosterUpload(c, targetSubject, middleware.CoursePath(c)+"/teacher/upload-roster")
+	if !ok {
+		return
 	}
-	redirectBack(c, targetSubject)
+
+	batch := models.ImportBatch{Subject: targetSubject, Kind: models.ImportKindRoster, FileName: table.FileName, CreatedBy: teacherEmail(c)}
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		return applyRosterImport(tx, &batch, rosters)
+	})
+	if err != nil {
+		c.String(500, "❌ 名單匯入失敗，資料未變更："+err.Error())
+		return
+	}
+	msg := fmt.Sprintf("✅ 名單匯入完成：新增 %d 人、更新 %d 人", batch.Created, batch.Updated)
+	if table.Encoding != "" {
+		msg += "（檔案編碼：" + utils.EncodingLabel(table.Encoding) + "）"
+	}
+	setFlash(c, msg)
+	redirectBack(c)
 }
 
 // --- 手動管理與解綁 ---
@@ -180,94 +249,221 @@ func PostRoster(c *gin.Context) {
 	class := strings.TrimSpace(c.PostForm("class"))
 
 	if sid != "" {
+		// 回收桶中的同一位學生會被還原並恢復修課
 		initializers.DB.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "student_id"}, {Name: "subject"}},
-			DoUpdates: clause.AssignmentColumns([]string{"class", "updated_at", "deleted_at"}),
-		}).Create(&models.Roster{StudentID: sid, Class: class, Subject: targetSubject})
+			DoUpdates: clause.AssignmentColumns([]string{"class", "status", "updated_at", "deleted_at"}),
+		}).Create(&models.Roster{StudentID: sid, Class: class, Subject: targetSubject, Status: models.RosterActive})
 	}
-	redirectBack(c, targetSubject)
+	redirectBack(c)
 }
 
 func PostGrade(c *gin.Context) {
 	targetSubject := getTargetSubject(c)
 	sid := utils.CleanID(c.PostForm("student_id"))
 	itemName := strings.TrimSpace(c.PostForm("item_name"))
-	score, _ := strconv.ParseFloat(c.PostForm("score"), 64)
+	score, status, ok := utils.ParseGradeCell(c.PostForm("score"))
+	if !ok {
+		c.String(400, "❌ 分數格式錯誤，請輸入數字、EX、ABS 或留空")
+		return
+	}
 
 	if sid != "" && itemName != "" {
-		initializers.DB.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "student_id"}, {Name: "item_name"}, {Name: "subject"}},
-			DoUpdates: clause.AssignmentColumns([]string{"score", "updated_at", "deleted_at"}),
-		}).Create(&models.Grade{StudentID: sid, ItemName: itemName, Score: score, Subject: targetSubject})
+		if !requireAssignedItems(c, targetSubject, []string{itemName}) {
+			return
+		}
+		audit := newGradeAudit(c, targetSubject, models.HistorySourceManual)
+		err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+			var old *models.Grade
+			var existing models.Grade
+			if tx.Where("student_id = ? AND item_name = ? AND subject = ?", sid, itemName, targetSubject).First(&existing).Error == nil {
+				old = &existing
+			}
+			audit.Change(sid, itemName, old, score, status)
+
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "student_id"}, {Name: "item_name"}, {Name: "subject"}},
+				DoUpdates: clause.AssignmentColumns([]string{"score", "status", "needs_review", "updated_at", "deleted_at"}),
+			}).Create(&models.Grade{StudentID: sid, ItemName: itemName, Score: score, Status: status, Subject: targetSubject, NeedsReview: middleware.Staff(c).Review}).Error
+			if err != nil {
+				return err
+			}
+			return audit.Save(tx)
+		})
+		if err != nil {
+			c.String(500, "資料庫寫入失敗")
+			return
+		}
 	}
-	redirectBack(c, targetSubject)
+	redirectBack(c)
 }
 
 func DeleteGrade(c *gin.Context) {
-	targetSubject := initializers.CurrentSubject
+	targetSubject := getTargetSubject(c)
 	sid := c.Query("student_id")
 	item := c.Query("item_name")
-	// 這裡保留普通的 Delete() 讓他變成軟刪除
-	initializers.DB.Where("student_id = ? AND item_name = ? AND subject = ?", sid, item, targetSubject).Delete(&models.Grade{})
-	redirectBack(c, targetSubject)
+
+	var existing models.Grade
+	if initializers.DB.Where("student_id = ? AND item_name = ? AND subject = ?", sid, item, targetSubject).First(&existing).Error == nil {
+		audit := newGradeAudit(c, targetSubject, models.HistorySourceManual)
+		audit.Delete(existing)
+		err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+			// 這裡保留普通的 Delete() 讓他變成軟刪除，可在回收桶還原
+			if err := tx.Delete(&existing).Error; err != nil {
+				return err
+			}
+			return audit.Save(tx)
+		})
+		if err != nil {
+			c.String(500, "資料庫寫入失敗")
+			return
+		}
+	}
+	redirectBack(c)
 }
 
-// 🌟 修正：精準的刪除單一學生名單與成績
-func DeleteSingleRoster(c *gin.Context) {
-	targetSubject := initializers.CurrentSubject
-	if initializers.IsAdminMode {
-		targetSubject = c.Query("subject")
+// ApproveGrades 確認助教登錄的成績；有帶 id 時只確認該筆，否則確認整門課程待確認的成績
+func ApproveGrades(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	query := initializers.DB.Model(&models.Grade{}).Where("subject = ? AND needs_review = ?", targetSubject, true)
+	if id := c.PostForm("id"); id != "" {
+		query = query.Where("id = ?", id)
+	}
+	result := query.Update("needs_review", false)
+	if result.Error != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
 	}
+	setFlash(c, fmt.Sprintf("✅ 已確認 %d 筆助教登錄的成績", result.RowsAffected))
+	redirectBack(c)
+}
+
+// DeleteSingleRoster 單一學生的刪除連結，改為標為退選而不刪除資料
+func DeleteSingleRoster(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
 	
 	sid := c.Query("student_id")
 	if sid != "" {
-		// 使用 Unscoped() 進行硬刪除，避免產生幽靈紀錄
-		initializers.DB.Unscoped().Where("student_id = ? AND subject = ?", sid, targetSubject).Delete(&models.Roster{})
-		initializers.DB.Unscoped().Where("student_id = ? AND subject = ?", sid, targetSubject).Delete(&models.Grade{})
+		// 不再刪除名單與成績，改標為退選：成績保留、不列入全班統計，誤按時改回修課中即可
+		initializers.DB.Model(&models.Roster{}).Where("student_id = ? AND subject = ?", sid, targetSubject).Update("status", models.RosterWithdrawn)
+		setFlash(c, "✅ "+sid+" 已標為退選，成績仍保留")
 	}
-	redirectBack(c, targetSubject)
+	redirectBack(c)
+}
+
+// SetRosterStatus 變更學生的修課狀態 (修課中、退選、旁聽、未完成、封存)
+func SetRosterStatus(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	sid := utils.CleanID(c.PostForm("student_id"))
+	status := c.PostForm("status")
+	if !slices.Contains(utils.RosterStatuses, status) {
+		c.String(400, "❌ 不支援的修課狀態")
+		return
+	}
+
+	result := initializers.DB.Model(&models.Roster{}).Where("student_id = ? AND subject = ?", sid, targetSubject).Update("status", status)
+	if result.Error != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.String(400, "❌ 名單中找不到此學號")
+		return
+	}
+	setFlash(c, "✅ "+sid+" 的修課狀態已改為「"+utils.RosterStatusLabel(status)+"」")
+	redirectBack(c)
 }
 
 // 解除綁定 Email
 func UnbindStudentEmail(c *gin.Context) {
-	targetSubject := initializers.CurrentSubject
-	if initializers.IsAdminMode {
-		targetSubject = c.Query("subject")
-	}
+	targetSubject := getTargetSubject(c)
 	
 	sid := c.Query("student_id")
-	// 硬刪除，讓學生可以重新綁定
-	initializers.DB.Unscoped().Where("student_id = ? AND subject = ?", sid, targetSubject).Delete(&models.Student{})
-	redirectBack(c, targetSubject)
+	// 軟刪除移到回收桶；學生重新綁定時會先永久刪除回收桶中的舊綁定
+	initializers.DB.Where("student_id = ? AND subject = ?", sid, targetSubject).Delete(&models.Student{})
+	setFlash(c, "🗑️ 已解除 "+sid+" 的 Email 綁定，可在回收桶還原")
+	redirectBack(c)
 }
 
-// --- 危險區：全部清空 ---
+// --- 危險區：全部清空 (移到回收桶) ---
 
 func ClearRoster(c *gin.Context) {
 	targetSubject := getTargetSubject(c)
-	initializers.DB.Unscoped().Where("subject = ?", targetSubject).Delete(&models.Roster{})
-	redirectBack(c, targetSubject)
+	result := initializers.DB.Where("subject = ?", targetSubject).Delete(&models.Roster{})
+	setFlash(c, fmt.Sprintf("🗑️ 已將 %d 位學生移到回收桶", result.RowsAffected))
+	redirectBack(c)
 }
 
 func ClearAllGrades(c *gin.Context) {
 	targetSubject := getTargetSubject(c)
-	initializers.DB.Unscoped().Where("subject = ?", targetSubject).Delete(&models.Grade{})
-	redirectBack(c, targetSubject)
+
+	var grades []models.Grade
+	initializers.DB.Where("subject = ?", targetSubject).Find(&grades)
+	audit := newGradeAudit(c, targetSubject, models.HistorySourceManual)
+	for _, g := range grades {
+		audit.Delete(g)
+	}
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subject = ?", targetSubject).Delete(&models.Grade{}).Error; err != nil {
+			return err
+		}
+		return audit.Save(tx)
+	})
+	if err != nil {
+		c.String(500, "資料庫寫入失敗")
+		return
+	}
+	setFlash(c, fmt.Sprintf("🗑️ 已將 %d 筆成績移到回收桶", len(grades)))
+	redirectBack(c)
 }
 
 // --- 內部輔助函式 ---
 
+// getTargetSubject 目前請求的課程 (由 middleware.ResolveCourse 依網址決定)
 func getTargetSubject(c *gin.Context) string {
-	if initializers.IsAdminMode {
-		return c.PostForm("subject")
+	return middleware.CourseCode(c)
+}
+
+// requireAssignedItems 助教只能登錄指派給他的項目；有其他項目時回應 403 並回傳 false
+func requireAssignedItems(c *gin.Context, subject string, items []string) bool {
+	perms := middleware.Staff(c)
+	scheme := utils.LoadScheme(subject)
+	var denied []string
+	for _, item := range items {
+		if !perms.CanGrade(scheme, item) {
+			denied = append(denied, item)
+		}
 	}
-	return initializers.CurrentSubject
+	if len(denied) > 0 {
+		c.String(403, "🚫 您沒有被指派登錄這些項目的成績："+strings.Join(denied, "、"))
+		return false
+	}
+	return true
+}
+
+// teacherEmail 目前登入老師的 Email (由 middleware.RequireTeacher 設定)
+func teacherEmail(c *gin.Context) string {
+	return c.GetString(middleware.TeacherEmailKey)
 }
 
-func redirectBack(c *gin.Context, subject string) {
-	path := "/teacher/dashboard"
-	if initializers.IsAdminMode {
-		path += "?subject=" + subject
+// setFlash 留一則訊息給下一次載入的教師後台顯示
+func setFlash(c *gin.Context, msg string) {
+	session := sessions.Default(c)
+	session.AddFlash(msg)
+	session.Save()
+}
+
+// popFlashes 取出並清除待顯示的訊息
+func popFlashes(c *gin.Context) []interface{} {
+	session := sessions.Default(c)
+	flashes := session.Flashes()
+	if len(flashes) > 0 {
+		session.Save()
 	}
-	c.Redirect(http.StatusSeeOther, path)
+	return flashes
+}
+
+func redirectBack(c *gin.Context) {
+	c.Redirect(http.StatusSeeOther, middleware.CoursePath(c)+"/teacher/dashboard")
 }
\ No newline at end of file
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/middleware"
+	"grade-system/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 回收桶中的資料種類 (表單的 kind 欄位)
+const (
+	trashGrade   = "grade"
+	trashRoster  = "roster"
+	trashBinding = "binding"
+)
+
+// trashModel 依種類回傳對應的資料表
+func trashModel(kind string) interface{} {
+	switch kind {
+	case trashGrade:
+		return &models.Grade{}
+	case trashRoster:
+		return &models.Roster{}
+	case trashBinding:
+		return &models.Student{}
+	}
+	return nil
+}
+
+// trashedQuery 只查回收桶中 (已軟刪除) 的資料
+func trashedQuery(db *gorm.DB, subject string) *gorm.DB {
+	return db.Unscoped().Where("subject = ? AND deleted_at IS NOT NULL", subject)
+}
+
+// trashPurgeInterval 伺服器執行期間清除過期回收桶的間隔
+const trashPurgeInterval = 24 * time.Hour
+
+// PurgeExpiredTrash 依科目永久刪除在回收桶中超過保留天數的成績、名單與綁定
+func PurgeExpiredTrash() {
+	if initializers.TrashRetentionDays <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -initializers.TrashRetentionDays)
+	for _, kind := range []string{trashGrade, trashRoster, trashBinding} {
+		var subjects []string
+		initializers.DB.Unscoped().Model(trashModel(kind)).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Distinct().Pluck("subject", &subjects)
+		for _, subject := range subjects {
+			result := trashedQuery(initializers.DB, subject).Where("deleted_at < ?", cutoff).Delete(trashModel(kind))
+			if result.Error != nil {
+				log.Printf("清除 %s 過期的回收桶失敗：%v", subject, result.Error)
+				continue
+			}
+			log.Printf("已永久刪除 %s 回收桶中過期的 %d 筆資料", subject, result.RowsAffected)
+		}
+	}
+}
+
+// StartTrashPurge 啟動時清除一次過期的回收桶，之後每天再清除一次
+func StartTrashPurge() {
+	PurgeExpiredTrash()
+	go func() {
+		for range time.Tick(trashPurgeInterval) {
+			PurgeExpiredTrash()
+		}
+	}()
+}
+
+// countTrash 回收桶中的資料筆數，顯示在教師後台的連結上
+func countTrash(subject string) int64 {
+	var total int64
+	for _, kind := range []string{trashGrade, trashRoster, trashBinding} {
+		var n int64
+		trashedQuery(initializers.DB, subject).Model(trashModel(kind)).Count(&n)
+		total += n
+	}
+	return total
+}
+
+// ShowTrash 列出回收桶中的成績、名單與 Email 綁定
+func ShowTrash(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	var grades []models.Grade
+	trashedQuery(initializers.DB, targetSubject).Order("deleted_at desc").Find(&grades)
+	var rosters []models.Roster
+	trashedQuery(initializers.DB, targetSubject).Order("deleted_at desc").Find(&rosters)
+	var bindings []models.Student
+	trashedQuery(initializers.DB, targetSubject).Order("deleted_at desc").Find(&bindings)
+
+	c.HTML(200, "trash.html", gin.H{
+		"Grades":        grades,
+		"Rosters":       rosters,
+		"Bindings":      bindings,
+		"RetentionDays": initializers.TrashRetentionDays,
+		"Subject":       targetSubject,
+		"AppName":       initializers.AppName,
+		"Base":          middleware.CoursePath(c),
+		"Flashes":       popFlashes(c),
+	})
+}
+
+// RestoreTrash 將回收桶中的一筆資料還原；還原的成績記進異動紀錄
+func RestoreTrash(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+	kind, id := c.PostForm("kind"), c.PostForm("id")
+	model := trashModel(kind)
+	if model == nil {
+		c.String(400, "❌ 不支援的資料種類")
+		return
+	}
+	if err := trashedQuery(initializers.DB, targetSubject).Where("id = ?", id).First(model).Error; err != nil {
+		c.String(400, "❌ 回收桶中找不到此筆資料")
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(model).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		g, ok := model.(*models.Grade)
+		if !ok {
+			return nil
+		}
+		audit := newGradeAudit(c, targetSubject, models.HistorySourceTrash)
+		audit.Change(g.StudentID, g.ItemName, nil, g.Score, g.Status)
+		return audit.Save(tx)
+	})
+	if err != nil {
+		c.String(500, "❌ 還原失敗："+err.Error())
+		return
+	}
+	setFlash(c, "♻️ 已還原")
+	redirectToTrash(c)
+}
+
+// PurgeTrash 永久刪除回收桶中的一筆資料；all=1 時清空整個回收桶
+func PurgeTrash(c *gin.Context) {
+	targetSubject := getTargetSubject(c)
+
+	kinds := []string{c.PostForm("kind")}
+	if c.PostForm("all") == "1" {
+		kinds = []string{trashGrade, trashRoster, trashBinding}
+	}
+	var purged int64
+	for _, kind := range kinds {
+		model := trashModel(kind)
+		if model == nil {
+			c.String(400, "❌ 不支援的資料種類")
+			return
+		}
+		q := trashedQuery(initializers.DB, targetSubject)
+		if c.PostForm("all") != "1" {
+			q = q.Where("id = ?", c.PostForm("id"))
+		}
+		result := q.Delete(model)
+		if result.Error != nil {
+			c.String(500, "資料庫寫入失敗")
+			return
+		}
+		purged += result.RowsAffected
+	}
+	setFlash(c, fmt.Sprintf("🗑️ 已永久刪除 %d 筆資料", purged))
+	redirectToTrash(c)
+}
+
+func redirectToTrash(c *gin.Context) {
+	c.Redirect(http.StatusSeeOther, middleware.CoursePath(c)+"/teacher/trash")
+}
@@ -3,17 +3,20 @@ package initializers
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
+// 登入方式 (AUTH_PROVIDER 等) 由 auth.Setup 讀取設定
 var (
-	GoogleOauthConfig *oauth2.Config
-	CurrentSubject    string
-	IsAdminMode       bool
-	AppName           string
+	// CurrentSubject APP_SUBJECT：網址 (/c/:course 或子網域) 沒有指定課程時使用的預設課程
+	CurrentSubject string
+	// IsAdminMode APP_MODE=admin：首頁為總管理後台，不提供學生頁面
+	IsAdminMode bool
+	AppName     string
+	// TrashRetentionDays 回收桶保留天數，超過後永久刪除；0 代表不自動清除
+	TrashRetentionDays int
 )
 
 func LoadEnvVariables() {
@@ -28,16 +31,13 @@ func InitConfig() {
 	if AppName == "" {
 		AppName = "學生分數平台"
 	}
+	TrashRetentionDays = 30
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days >= 0 {
+		TrashRetentionDays = days
+	}
+
 	if os.Getenv("APP_MODE") == "admin" {
 		IsAdminMode = true
 		AppName = "教師總管理後台"
 	}
-
-	GoogleOauthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
-		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
-		Endpoint:     google.Endpoint,
-	}
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package initializers
 import (
 	"log"
 	"os"
+	"strings"
 
 	"grade-system/models"
 
@@ -33,6 +34,63 @@ func ConnectToDB() {
 		log.Fatal("資料庫連線失敗: ", err)
 	}
 
-	// 自動遷移
-	DB.AutoMigrate(&models.Student{}, &models.Grade{}, &models.Roster{})
-}
\ No newline at end of file
+	// 自動遷移：先建立課程並補齊既有資料用到的科目，成績、名單、學生的外鍵才建得起來
+	DB.AutoMigrate(&models.Term{}, &models.Course{}, &models.Account{}, &models.CourseStaff{})
+	seedCourses()
+	seedAccounts()
+	seedWhitelistStaff()
+	DB.AutoMigrate(&models.Student{}, &models.Grade{}, &models.Roster{}, &models.GradeCategory{}, &models.GradeItem{}, &models.LetterCutoff{}, &models.GradeHistory{}, &models.ImportBatch{}, &models.RosterHistory{}, &models.GradeItemHistory{}, &models.ColumnMapping{})
+}
+
+// seedCourses 將部署設定的 APP_SUBJECT 與既有成績、名單、學生用到的科目代碼補建成課程
+func seedCourses() {
+	codes := make(map[string]bool)
+	if subject := os.Getenv("APP_SUBJECT"); subject != "" {
+		codes[subject] = true
+	}
+	for _, table := range []string{"grades", "rosters", "students"} {
+		if !DB.Migrator().HasTable(table) {
+			continue
+		}
+		var subjects []string
+		DB.Table(table).Distinct("subject").Pluck("subject", &subjects)
+		for _, s := range subjects {
+			codes[s] = true
+		}
+	}
+
+	for code := range codes {
+		DB.Where("code = ?", code).Attrs(models.Course{Name: code}).FirstOrCreate(&models.Course{Code: code})
+	}
+}
+
+// seedWhitelistStaff 舊版每個部署以 TEACHER_WHITELIST 指定該科的老師；APP_SUBJECT 還沒有任何課程人員時，
+// 將這些 Email 轉成該課程的授課老師 (只做一次，之後在總管理後台指派)，不會因此成為全站管理員
+func seedWhitelistStaff() {
+	subject := os.Getenv("APP_SUBJECT")
+	if subject == "" {
+		return
+	}
+	var count int64
+	DB.Unscoped().Model(&models.CourseStaff{}).Where("subject = ?", subject).Count(&count)
+	if count > 0 {
+		return
+	}
+	for _, email := range strings.Split(os.Getenv("TEACHER_WHITELIST"), ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			DB.Where("subject = ? AND email = ?", subject, email).Attrs(models.CourseStaff{Role: models.RoleOwner}).FirstOrCreate(&models.CourseStaff{Subject: subject, Email: email})
+		}
+	}
+}
+
+// seedAccounts 為既有的學生綁定補建帳號，原本在各科分別註冊的同一個 Email 合併成一個帳號，沿用最早註冊時的姓名
+func seedAccounts() {
+	if !DB.Migrator().HasTable("students") {
+		return
+	}
+	var students []models.Student
+	DB.Unscoped().Where("email <> '' AND email NOT IN (?)", DB.Model(&models.Account{}).Select("email")).Order("id asc").Find(&students)
+	for _, s := range students {
+		DB.Where("email = ?", s.Email).Attrs(models.Account{Name: s.Name}).FirstOrCreate(&models.Account{Email: s.Email})
+	}
+}
@@ -11,23 +11,98 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// RequireTeacher 確保使用者是老師
+// TeacherEmailKey 通過 RequireTeacher 後，目前老師的 Email 存在 gin.Context 的這個 key
+const TeacherEmailKey = "teacher_email"
+
+// AdminSessionKey 目前老師是否從總管理後台登入
+const AdminSessionKey = "admin_session"
+
+// AccountSessionKey 一般站台登入後，session 中存的是帳號 ID；總管理後台另外使用 user_id
+const AccountSessionKey = "account_id"
+
+// StaffKey 通過 RequireTeacher 後，目前老師在課程中的權限 (utils.StaffPermissions) 存在 gin.Context 的這個 key
+const StaffKey = "staff"
+
+// RequireTeacher 確保使用者是這門課程的老師或助教 (或全站管理員)
 func RequireTeacher(c *gin.Context) {
 	session := sessions.Default(c)
-	uid := session.Get("user_id")
-	if uid == nil {
-		c.Redirect(302, "/")
+	email, isAdminSession := "", false
+	if uid := session.Get("user_id"); uid != nil && strings.HasPrefix(fmt.Sprintf("%v", uid), "ADMIN_") {
+		email, isAdminSession = strings.TrimPrefix(fmt.Sprintf("%v", uid), "ADMIN_"), true
+	} else if id := session.Get(AccountSessionKey); id != nil {
+		var account models.Account
+		if initializers.DB.First(&account, id).Error == nil {
+			email = account.Email
+		}
+	}
+	if email == "" {
+		c.Redirect(302, CoursePath(c)+"/")
 		c.Abort()
 		return
 	}
-	isAdminSession := strings.HasPrefix(fmt.Sprintf("%v", uid), "ADMIN_")
-	if !isAdminSession {
-		var s models.Student
-		if err := initializers.DB.Scopes(utils.FilterSubject).First(&s, uid).Error; err != nil || !utils.IsTeacher(s.Email) {
-			c.String(403, "🚫 權限不足")
-			c.Abort()
-			return
-		}
+	perms := utils.CoursePermissions(email, CourseCode(c))
+	if perms.Role == "" {
+		c.String(403, "🚫 權限不足")
+		c.Abort()
+		return
+	}
+	c.Set(TeacherEmailKey, email)
+	c.Set(AdminSessionKey, isAdminSession)
+	c.Set(StaffKey, perms)
+	c.Next()
+}
+
+// Staff 目前老師在課程中的權限 (由 RequireTeacher 設定)
+func Staff(c *gin.Context) utils.StaffPermissions {
+	p, _ := c.Get(StaffKey)
+	perms, _ := p.(utils.StaffPermissions)
+	return perms
+}
+
+// RequireGrader 只允許可以登錄成績的角色
+func RequireGrader(c *gin.Context) {
+	requirePermission(c, Staff(c).Grade)
+}
+
+// RequireManager 只允許可以管理名單與評分方式的角色
+func RequireManager(c *gin.Context) {
+	requirePermission(c, Staff(c).Manage)
+}
+
+// RequireOwner 只允許授課老師與管理員執行危險操作
+func RequireOwner(c *gin.Context) {
+	requirePermission(c, Staff(c).Owner)
+}
+
+func requirePermission(c *gin.Context, allowed bool) {
+	if !allowed {
+		c.String(403, "🚫 權限不足：您在此課程的角色為「"+utils.StaffRoleLabel(Staff(c).Role)+"」")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// RequireAdmin 確保是從總管理後台登入的全站管理員
+func RequireAdmin(c *gin.Context) {
+	uid := sessions.Default(c).Get("user_id")
+	email := strings.TrimPrefix(fmt.Sprintf("%v", uid), "ADMIN_")
+	if uid == nil || !strings.HasPrefix(fmt.Sprintf("%v", uid), "ADMIN_") || !utils.IsAdmin(email) {
+		c.String(403, "🚫 權限不足")
+		c.Abort()
+		return
+	}
+	c.Set(TeacherEmailKey, email)
+	c.Next()
+}
+
+// RequireWritableCourse 擋下對已封存課程 (或已封存學期) 的修改，瀏覽不受影響
+func RequireWritableCourse(c *gin.Context) {
+	var course models.Course
+	if initializers.DB.Where("code = ?", CourseCode(c)).First(&course).Error == nil && utils.CourseReadOnly(course) {
+		c.String(403, "📦 此課程已封存，只能瀏覽，不能修改")
+		c.Abort()
+		return
 	}
 	c.Next()
-}
\ No newline at end of file
+}
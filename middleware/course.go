@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"grade-system/initializers"
+	"grade-system/models"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 目前請求的課程代碼與網址前綴存在 gin.Context 的這兩個 key
+const (
+	CourseKey     = "course"
+	CoursePathKey = "course_path"
+)
+
+// ResolveCourse 決定這次請求的課程，依序為：網址路徑 /c/:course、子網域 (例如 circuit.teaegg.space)、部署設定的 APP_SUBJECT
+func ResolveCourse(c *gin.Context) {
+	code, path := c.Param("course"), ""
+	if code != "" {
+		if !courseExists(code) {
+			c.String(404, "❌ 找不到課程「"+code+"」")
+			c.Abort()
+			return
+		}
+		path = "/c/" + code
+	}
+	if code == "" {
+		code = subdomainCourse(c.Request.Host)
+	}
+	if code == "" {
+		code = initializers.CurrentSubject
+	}
+	c.Set(CourseKey, code)
+	c.Set(CoursePathKey, path)
+	c.Next()
+}
+
+// CourseCode 目前請求的課程代碼；總管理後台等不屬於任何課程的頁面為空字串
+func CourseCode(c *gin.Context) string {
+	return c.GetString(CourseKey)
+}
+
+// CoursePath 目前課程的網址前綴，以子網域或 APP_SUBJECT 決定課程時為空字串；站內連結與轉址都接在它後面
+func CoursePath(c *gin.Context) string {
+	return c.GetString(CoursePathKey)
+}
+
+// subdomainCourse 以網域的第一段作為課程代碼，只有該課程存在時才採用
+func subdomainCourse(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 || net.ParseIP(host) != nil {
+		return ""
+	}
+	if code := strings.ToLower(labels[0]); courseExists(code) {
+		return code
+	}
+	return ""
+}
+
+func courseExists(code string) bool {
+	var count int64
+	initializers.DB.Model(&models.Course{}).Where("code = ?", code).Count(&count)
+	return count > 0
+}
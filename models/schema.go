@@ -1,8 +1,62 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
 
-// Student 代表學生帳號資訊 (用 Google 登入註冊的資料)
+	"gorm.io/gorm"
+)
+
+// Term 學期；封存後該學期所有課程都變成唯讀
+type Term struct {
+	gorm.Model
+	Code   string `gorm:"uniqueIndex;not null"` // 學期代碼，例如 114-1
+	Name   string // 顯示名稱，例如 114 學年度第 1 學期
+	Status string `gorm:"not null;default:''"` // 與課程共用 Course* 狀態常數
+}
+
+// Course 某學期開設的一門課程；其他資料表的 Subject 欄位存的是課程代碼，
+// 同一門課每學期各有一個代碼 (例如 circuit、circuit-114-2)，由「延續到下學期」建立
+type Course struct {
+	gorm.Model
+	Code     string `gorm:"uniqueIndex;not null"` // 課程代碼，建立後不可修改 (例如 circuit)
+	Name     string // 顯示名稱，可隨時改名
+	Semester string // 所屬學期的代碼 (Term.Code)，舊資料補建的課程可能為空白
+	Status   string `gorm:"not null;default:''"` // 見 Course* 常數
+}
+
+// 課程狀態；空字串為開課中，封存的課程只能瀏覽
+const (
+	CourseActive   = ""
+	CourseArchived = "archived"
+)
+
+// CourseStaff 老師與助教在課程中的角色；Subject 為空白的是全站管理員，可管理所有課程
+type CourseStaff struct {
+	gorm.Model
+	Subject string `gorm:"uniqueIndex:idx_staff_subject_email"`
+	Email   string `gorm:"uniqueIndex:idx_staff_subject_email;not null"`
+	Role    string `gorm:"not null"` // 見 Role* 常數
+	Items   string // 登錄成績的助教負責的評量項目或分類名稱，逗號分隔
+}
+
+// 課程人員的角色
+const (
+	RoleOwner     = "owner"      // 授課老師：所有操作，包含清空名單、成績等危險操作
+	RoleCoTeacher = "co-teacher" // 協同教學：名單、成績與評分方式，不含危險操作
+	RoleTAGrader  = "ta-grade"   // 助教 (登錄成績)：只能上傳與登錄成績
+	RoleTAReader  = "ta-read"    // 助教 (唯讀)：只能瀏覽與匯出
+	RoleAdmin     = "admin"      // 全站管理員
+)
+
+// Account 登入帳號，一個 Email 一個帳號，所有課程共用
+type Account struct {
+	gorm.Model
+	Email        string `gorm:"uniqueIndex;not null"`
+	Name         string
+	PasswordHash string // 本機帳號登入 (AUTH_PROVIDER=local) 的 bcrypt 雜湊，其他登入方式留空
+}
+
+// Student 帳號在某門課程的綁定 (選課)：以學號驗證名單後建立，每門課各一筆，以 Email 對應到帳號
 type Student struct {
 	gorm.Model
 	StudentID string `gorm:"uniqueIndex:idx_sid_subject"`
@@ -10,17 +64,29 @@ type Student struct {
 	Class     string
 	Email     string `gorm:"uniqueIndex:idx_email_subject"`
 	Subject   string `gorm:"uniqueIndex:idx_sid_subject;uniqueIndex:idx_email_subject"`
+	Course    Course `gorm:"foreignKey:Subject;references:Code"`
 }
 
 // Grade 代表單一成績紀錄
 type Grade struct {
 	gorm.Model
-	StudentID string  `gorm:"index:idx_grade_item_subject,unique"`
-	ItemName  string  `gorm:"index:idx_grade_item_subject,unique"`
-	Score     float64 
-	Subject   string  `gorm:"index:idx_grade_item_subject,unique;not null"`
+	StudentID   string `gorm:"index:idx_grade_item_subject,unique"`
+	ItemName    string `gorm:"index:idx_grade_item_subject,unique"`
+	Score       float64
+	Subject     string `gorm:"index:idx_grade_item_subject,unique;not null"`
+	Status      string `gorm:"default:''"`    // 空字串代表有分數，其餘見 GradeMissing 等常數
+	NeedsReview bool   `gorm:"default:false"` // 助教登錄後尚待老師確認
+	Course      Course `gorm:"foreignKey:Subject;references:Code"`
 }
 
+// 成績狀態 (Grade.Status)；非 GradeScored 時 Score 一律為 0
+const (
+	GradeScored  = ""
+	GradeMissing = "missing" // 空白：未繳交或尚未登錄
+	GradeExcused = "excused" // EX：請假核准、免考
+	GradeAbsent  = "absent"  // 缺考
+)
+
 // Roster 用於記錄老師上傳的名單原始資料
 type Roster struct {
 	gorm.Model
@@ -28,4 +94,132 @@ type Roster struct {
 	Name      string // 🌟 新增：存取 CSV 中的姓名
 	Class     string
 	Subject   string `gorm:"uniqueIndex:idx_roster_sid_subject"`
-}
\ No newline at end of file
+	Status    string `gorm:"not null;default:''"` // 見 Roster* 常數
+	Course    Course `gorm:"foreignKey:Subject;references:Code"`
+}
+
+// 名單狀態；空字串為修課中，只有修課中的學生列入全班統計，其餘狀態的成績仍保留
+const (
+	RosterActive     = ""
+	RosterWithdrawn  = "withdrawn"  // 退選
+	RosterAudit      = "audit"      // 旁聽
+	RosterIncomplete = "incomplete" // 成績未完成 (I)，之後補交再改回修課中
+	RosterArchived   = "archived"   // 封存：例如轉班或休學，資料留存備查
+)
+
+// GradeCategory 代表科目評分方式中的一個分類 (例如：作業 30%)
+type GradeCategory struct {
+	gorm.Model
+	Subject    string  `gorm:"uniqueIndex:idx_category_name_subject;not null"`
+	Name       string  `gorm:"uniqueIndex:idx_category_name_subject"`
+	Weight     float64 // 佔學期總成績的百分比
+	SortOrder  int
+	DropLowest int // 去掉最低的 N 次，0 代表全部採計
+	BestOf     int // 只採計最好的 M 次，0 代表不限 (設定時優先於 DropLowest)
+	// 缺考預設以 0 分計入、空白預設不計入；EX 一律不計入
+	MissingAsZero bool
+	ExcuseAbsent  bool
+}
+
+// GradeItem 代表單一評量項目的設定 (所屬分類與滿分)
+type GradeItem struct {
+	gorm.Model
+	Subject    string  `gorm:"uniqueIndex:idx_item_name_subject;not null"`
+	ItemName   string  `gorm:"uniqueIndex:idx_item_name_subject"`
+	CategoryID *uint   // nil 代表尚未歸類
+	MaxPoints  float64 `gorm:"default:100"`
+	CurveType  string  // 空字串代表未調分，其餘見 utils.Curve* 常數
+	CurveParam float64 // 加分的分數或線性調整的倍率；原始分數不會被覆寫
+}
+
+// LetterCutoff 代表等第對照表中的一列 (總分 >= MinScore 即為此等第)
+type LetterCutoff struct {
+	gorm.Model
+	Subject  string `gorm:"uniqueIndex:idx_letter_subject;not null"`
+	Letter   string `gorm:"uniqueIndex:idx_letter_subject"`
+	MinScore float64
+	GPA      float64
+}
+
+// GradeHistory 記錄每一次成績變動：舊值、新值、誰改的與來源
+type GradeHistory struct {
+	gorm.Model
+	Subject   string `gorm:"index:idx_history_subject_student;not null"`
+	StudentID string `gorm:"index:idx_history_subject_student"`
+	ItemName  string
+	Action    string // 見 HistoryAction* 常數
+	OldScore  float64
+	OldStatus string
+	// OldNeedsReview 變動前是否尚待老師確認，復原匯入時一併還原
+	OldNeedsReview bool
+	NewScore       float64
+	NewStatus      string
+	ChangedBy      string // 老師 Email
+	Source         string // 見 HistorySource* 常數
+	BatchID        *uint  `gorm:"index"` // 由檔案匯入時對應的 ImportBatch
+}
+
+// 成績變動類型與來源 (GradeHistory.Action / GradeHistory.Source)
+const (
+	HistoryActionCreate = "create"
+	HistoryActionUpdate = "update"
+	HistoryActionDelete = "delete"
+
+	HistorySourceCSV        = "csv"
+	HistorySourceManual     = "manual"
+	HistorySourceCurve      = "curve"
+	HistorySourceRollback   = "rollback"
+	HistorySourceRosterSync = "roster-sync"
+	HistorySourceTrash      = "trash"
+)
+
+// ImportBatch 一次成績或名單檔案匯入，復原時依此找回匯入前的狀態
+type ImportBatch struct {
+	gorm.Model
+	Subject      string `gorm:"index;not null"`
+	Kind         string // 見 ImportKind* 常數
+	FileName     string
+	CreatedBy    string // 老師 Email
+	Created      int
+	Updated      int
+	Removed      int // 名單同步時退選、封存或刪除的人數
+	RolledBackAt *time.Time
+	RolledBackBy string
+}
+
+// 匯入的檔案種類 (ImportBatch.Kind)
+const (
+	ImportKindGrades = "grades"
+	ImportKindRoster = "roster"
+)
+
+// RosterHistory 名單匯入時每位學生的變動，供復原使用
+type RosterHistory struct {
+	gorm.Model
+	BatchID   uint `gorm:"index;not null"`
+	Subject   string
+	StudentID string
+	Action    string // 見 HistoryAction* 常數
+	OldClass  string
+	OldName   string
+	OldStatus string
+}
+
+// GradeItemHistory 成績匯入時滿分設定的變動，供復原使用
+type GradeItemHistory struct {
+	gorm.Model
+	BatchID      uint `gorm:"index;not null"`
+	Subject      string
+	ItemName     string
+	Action       string // 見 HistoryAction* 常數
+	OldMaxPoints float64
+}
+
+// ColumnMapping 老師為某種檔案版面設定的欄位對應，之後上傳同樣標題的檔案時直接套用
+type ColumnMapping struct {
+	gorm.Model
+	Subject   string `gorm:"uniqueIndex:idx_mapping_layout;not null"`
+	Kind      string `gorm:"uniqueIndex:idx_mapping_layout"` // 見 ImportKind* 常數
+	Signature string `gorm:"uniqueIndex:idx_mapping_layout"` // 標題列的雜湊
+	Mapping   string // 各欄用途，格式見 controllers 的 columnMapping
+}
@@ -4,9 +4,11 @@ import (
 	"embed"
 	"html/template"
 	"io/fs"
+	"log"
 	"net/http"
 	"os"
 
+	"grade-system/auth"
 	"grade-system/controllers"
 	"grade-system/initializers"
 	"grade-system/middleware"
@@ -28,6 +30,10 @@ func SetupRouter() *gin.Engine {
 	initializers.LoadEnvVariables()
 	initializers.ConnectToDB()
 	initializers.InitConfig()
+	if err := auth.Setup(); err != nil {
+		log.Fatal("登入設定錯誤：", err)
+	}
+	controllers.StartTrashPurge()
 
 	r := gin.Default()
 
@@ -37,7 +43,16 @@ func SetupRouter() *gin.Engine {
 
 	// 2. 設定 HTML 樣板 (使用 embed，不依賴外部資料夾)
 	templ := template.Must(template.New("").Funcs(template.FuncMap{
-		"inc": utils.Inc,
+		"inc":           utils.Inc,
+		"percent":       utils.Percent,
+		"statusLabel":   utils.GradeStatusLabel,
+		"sourceLabel":   utils.HistorySourceLabel,
+		"actionLabel":   utils.HistoryActionLabel,
+		"kindLabel":     utils.ImportKindLabel,
+		"encodingLabel": utils.EncodingLabel,
+		"formatLabel":   utils.GradebookFormatLabel,
+		"rosterLabel":   utils.RosterStatusLabel,
+		"roleLabel":     utils.StaffRoleLabel,
 	}).ParseFS(templatesFS, "templates/*"))
 	r.SetHTMLTemplate(templ)
 
@@ -46,31 +61,89 @@ func SetupRouter() *gin.Engine {
 	r.Use(sessions.Sessions("mysession", store))
 
 	// --- 路由設定 ---
-	r.GET("/", controllers.ShowIndex)
-	r.GET("/login", controllers.Login)
+	// 每門課程的頁面可從 /c/:course/... 進入，或以子網域、APP_SUBJECT 決定課程時直接掛在根目錄
+	// 只有課程頁面需要查詢課程，靜態檔案、登入回呼與總管理後台不經過 ResolveCourse
+	courses := r.Group("", middleware.ResolveCourse)
+	registerCourseRoutes(courses)
+	registerCourseRoutes(courses.Group("/c/:course"))
 	r.GET("/auth/callback", controllers.Callback)
+	if auth.IsFake() {
+		r.Any(auth.FakeIssuerPath+"/*path", gin.WrapH(auth.NewFakeIssuer()))
+	}
 	r.GET("/logout", controllers.Logout)
 
-	r.GET("/register", controllers.ShowRegister)
-	r.POST("/register", controllers.Register)
-	r.GET("/my-grades", controllers.ShowMyGrades)
+	r.GET("/admin", controllers.ShowAdminDashboard)
+	r.GET("/admin/login", controllers.AdminLogin)
 
-	teacher := r.Group("/teacher")
-	teacher.Use(middleware.RequireTeacher)
+	admin := r.Group("/admin")
+	admin.Use(middleware.RequireAdmin)
 	{
-		teacher.GET("/dashboard", controllers.TeacherDashboard)
-		teacher.POST("/upload", controllers.UploadGrades)
-		teacher.POST("/upload-roster", controllers.UploadRoster)
-
-		teacher.POST("/roster/post", controllers.PostRoster)
-		teacher.POST("/grade/post", controllers.PostGrade)
-		teacher.GET("/grade/delete", controllers.DeleteGrade)
-		teacher.GET("/roster/delete-one", controllers.DeleteSingleRoster)
-		teacher.GET("/student/unbind", controllers.UnbindStudentEmail)
-
-		teacher.POST("/delete-roster", controllers.ClearRoster)
-		teacher.POST("/delete-all", controllers.ClearAllGrades)
+		admin.POST("/course", controllers.CreateCourse)
+		admin.POST("/course/update", controllers.UpdateCourse)
+		admin.POST("/course/archive", controllers.SetCourseArchived)
+		admin.POST("/course/rollover", controllers.RolloverCourse)
+		admin.POST("/term", controllers.CreateTerm)
+		admin.POST("/term/archive", controllers.SetTermArchived)
+		admin.POST("/staff", controllers.SaveCourseStaff)
+		admin.POST("/staff/remove", controllers.RemoveCourseStaff)
+		admin.POST("/password", controllers.SetLocalPassword)
 	}
 
 	return r
-}
\ No newline at end of file
+}
+
+// registerCourseRoutes 註冊屬於單一課程的學生與教師頁面
+func registerCourseRoutes(g *gin.RouterGroup) {
+	g.GET("/", controllers.ShowIndex)
+	g.GET("/login", controllers.Login)
+	g.POST("/login", controllers.LocalLogin)
+
+	g.GET("/register", controllers.ShowRegister)
+	g.POST("/register", controllers.Register)
+	g.GET("/my-grades", controllers.ShowMyGrades)
+
+	teacher := g.Group("/teacher")
+	teacher.Use(middleware.RequireTeacher)
+	// 會修改資料的路由：封存的課程只能瀏覽，並依課程角色限制
+	edit := teacher.Group("", middleware.RequireWritableCourse)
+	grade := edit.Group("", middleware.RequireGrader)
+	manage := edit.Group("", middleware.RequireManager)
+	owner := edit.Group("", middleware.RequireOwner)
+	{
+		teacher.GET("/dashboard", controllers.TeacherDashboard)
+		grade.POST("/upload", controllers.UploadGrades)
+		grade.POST("/upload/confirm", controllers.ConfirmGradeUpload)
+		manage.POST("/upload-roster", controllers.UploadRoster)
+		manage.POST("/roster/sync", controllers.PreviewRosterSync)
+		manage.POST("/roster/sync/confirm", controllers.ConfirmRosterSync)
+		manage.POST("/batch/rollback", controllers.RollbackImportBatch)
+
+		manage.POST("/roster/post", controllers.PostRoster)
+		grade.POST("/grade/post", controllers.PostGrade)
+		manage.GET("/grade/delete", controllers.DeleteGrade)
+		manage.POST("/grade/approve", controllers.ApproveGrades)
+		manage.GET("/roster/delete-one", controllers.DeleteSingleRoster)
+		manage.POST("/roster/status", controllers.SetRosterStatus)
+		manage.GET("/student/unbind", controllers.UnbindStudentEmail)
+
+		manage.POST("/scheme/category", controllers.SaveGradeCategory)
+		manage.GET("/scheme/category/delete", controllers.DeleteGradeCategory)
+		manage.POST("/scheme/item", controllers.SaveGradeItem)
+		manage.POST("/scheme/letters", controllers.SaveLetterCutoffs)
+
+		teacher.GET("/curve", controllers.ShowCurvePreview)
+		manage.POST("/curve", controllers.SaveCurve)
+
+		teacher.GET("/history", controllers.ShowGradeHistory)
+		teacher.GET("/trash", controllers.ShowTrash)
+		manage.POST("/trash/restore", controllers.RestoreTrash)
+		owner.POST("/trash/purge", controllers.PurgeTrash)
+		teacher.GET("/export.csv", controllers.ExportGradebookCSV)
+		teacher.GET("/export.xlsx", controllers.ExportGradebookXLSX)
+		teacher.GET("/export/moodle.csv", controllers.ExportMoodleCSV)
+		teacher.GET("/export/classroom.csv", controllers.ExportClassroomCSV)
+
+		owner.POST("/delete-roster", controllers.ClearRoster)
+		owner.POST("/delete-all", controllers.ClearAllGrades)
+	}
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"grade-system/initializers"
+	"grade-system/models"
+)
+
+// CourseReadOnly 課程本身或所屬學期已封存時只能瀏覽，不能修改
+func CourseReadOnly(course models.Course) bool {
+	if course.Status == models.CourseArchived {
+		return true
+	}
+	if course.Semester == "" {
+		return false
+	}
+	var term models.Term
+	err := initializers.DB.Where("code = ?", course.Semester).First(&term).Error
+	return err == nil && term.Status == models.CourseArchived
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// 調分方式 (存於 models.GradeItem.CurveType)
+const (
+	CurveNone   = ""
+	CurveFlat   = "flat"   // 每人加固定分數
+	CurveLinear = "linear" // 等比例放大，讓全班最高分變成滿分
+	CurveSqrt   = "sqrt"   // 開根號乘十 (依滿分換算)
+)
+
+// ApplyCurve 依調分方式換算分數，結果不超過滿分也不低於 0
+func ApplyCurve(curveType string, param, raw, maxPoints float64) float64 {
+	if maxPoints <= 0 {
+		maxPoints = DefaultMaxPoints
+	}
+	curved := raw
+	switch curveType {
+	case CurveFlat:
+		curved = raw + param
+	case CurveLinear:
+		curved = raw * param
+	case CurveSqrt:
+		curved = math.Sqrt(math.Max(raw, 0)/maxPoints) * maxPoints
+	default:
+		return raw
+	}
+	return Round2(math.Min(math.Max(curved, 0), maxPoints))
+}
+
+// LinearCurveFactor 讓最高原始分數剛好變成滿分的倍率
+func LinearCurveFactor(highestRaw, maxPoints float64) float64 {
+	if highestRaw <= 0 {
+		return 1
+	}
+	return maxPoints / highestRaw
+}
+
+// CurveLabel 調分方式的中文說明
+func CurveLabel(curveType string, param float64) string {
+	switch curveType {
+	case CurveFlat:
+		return fmt.Sprintf("每人加 %g 分", param)
+	case CurveLinear:
+		return fmt.Sprintf("等比例放大 ×%.3f", param)
+	case CurveSqrt:
+		return "開根號調分"
+	}
+	return "未調分"
+}
+
+// EffectiveScore 回傳項目套用調分後的分數
+func (s GradingScheme) EffectiveScore(itemName string, raw float64) float64 {
+	item, ok := s.Items[itemName]
+	if !ok || item.CurveType == CurveNone {
+		return raw
+	}
+	return ApplyCurve(item.CurveType, item.CurveParam, raw, s.MaxPoints(itemName))
+}
+
+// Histogram 將分數依得分率分成 0-9%、10-19% ... 90-100% 共十組
+func Histogram(scores map[string]float64, maxPoints float64) []int {
+	buckets := make([]int, 10)
+	for _, v := range scores {
+		b := int(Percent(v, maxPoints) / 10)
+		buckets[min(max(b, 0), 9)]++
+	}
+	return buckets
+}
+
+// HistogramLabels Histogram 各組的標籤
+var HistogramLabels = []string{"0-9", "10-19", "20-29", "30-39", "40-49", "50-59", "60-69", "70-79", "80-89", "90-100"}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 上傳 CSV 的文字編碼 (表單的 encoding 欄位)，留空代表自動偵測
+const (
+	EncodingAuto    = ""
+	EncodingUTF8    = "utf-8"
+	EncodingBig5    = "big5"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+)
+
+// EncodingLabel 編碼的顯示名稱
+func EncodingLabel(enc string) string {
+	switch enc {
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingBig5:
+		return "Big5"
+	case EncodingUTF16LE:
+		return "UTF-16 LE"
+	case EncodingUTF16BE:
+		return "UTF-16 BE"
+	}
+	return "自動偵測"
+}
+
+// DetectEncoding 依 BOM 與內容猜測編碼：有 BOM 依 BOM，合法的 UTF-8 視為 UTF-8，其餘視為 Big5
+func DetectEncoding(raw []byte) string {
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+	// 沒有 BOM 的 UTF-16：ASCII 字元的高位元組是 0，會集中在偶數或奇數位置
+	if len(raw) >= 4 {
+		evenZero, oddZero := 0, 0
+		for i := 0; i+1 < len(raw) && i < 512; i += 2 {
+			if raw[i] == 0 {
+				evenZero++
+			}
+			if raw[i+1] == 0 {
+				oddZero++
+			}
+		}
+		pairs := min(len(raw), 512) / 2
+		if oddZero*2 > pairs && evenZero*4 < oddZero {
+			return EncodingUTF16LE
+		}
+		if evenZero*2 > pairs && oddZero*4 < evenZero {
+			return EncodingUTF16BE
+		}
+	}
+	if utf8.Valid(raw) {
+		return EncodingUTF8
+	}
+	return EncodingBig5
+}
+
+// DecodeText 將上傳的檔案轉成 UTF-8 並去掉 BOM；enc 留空時自動偵測，回傳實際使用的編碼
+func DecodeText(raw []byte, enc string) ([]byte, string, error) {
+	if enc == EncodingAuto {
+		enc = DetectEncoding(raw)
+	}
+
+	var decoder *encoding.Decoder
+	switch enc {
+	case EncodingUTF8:
+		text := bytes.TrimPrefix(raw, []byte{0xEF, 0xBB, 0xBF})
+		if !utf8.Valid(text) {
+			return nil, enc, errors.New("檔案不是 UTF-8 編碼，請改選其他編碼")
+		}
+		return text, enc, nil
+	case EncodingBig5:
+		decoder = traditionalchinese.Big5.NewDecoder()
+	case EncodingUTF16LE:
+		decoder = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case EncodingUTF16BE:
+		decoder = unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
+	default:
+		return nil, enc, errors.New("不支援的檔案編碼")
+	}
+
+	text, err := decoder.Bytes(raw)
+	if err != nil {
+		return nil, enc, errors.New("無法以 " + EncodingLabel(enc) + " 讀取檔案，請改選其他編碼")
+	}
+	return bytes.TrimPrefix(text, []byte{0xEF, 0xBB, 0xBF}), enc, nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const encodingFixture = "學號,姓名,期中考\nS001,王小明,85\n"
+
+func TestDecodeText(t *testing.T) {
+	big5, err := traditionalchinese.Big5.NewEncoder().Bytes([]byte(encodingFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf16le, _ := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(encodingFixture))
+	utf16be, _ := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(encodingFixture))
+
+	tests := []struct {
+		name     string
+		raw      []byte
+		override string
+		detected string
+	}{
+		{name: "UTF-8", raw: []byte(encodingFixture), detected: EncodingUTF8},
+		{name: "UTF-8 BOM", raw: append([]byte{0xEF, 0xBB, 0xBF}, encodingFixture...), detected: EncodingUTF8},
+		{name: "Big5", raw: big5, detected: EncodingBig5},
+		{name: "UTF-16 LE BOM", raw: append([]byte{0xFF, 0xFE}, utf16le...), detected: EncodingUTF16LE},
+		{name: "UTF-16 BE BOM", raw: append([]byte{0xFE, 0xFF}, utf16be...), detected: EncodingUTF16BE},
+		{name: "UTF-16 LE 沒有 BOM", raw: utf16le, detected: EncodingUTF16LE},
+		{name: "UTF-16 BE 沒有 BOM", raw: utf16be, detected: EncodingUTF16BE},
+		{name: "手動指定 Big5", raw: big5, override: EncodingBig5, detected: EncodingBig5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.override == "" {
+				if got := DetectEncoding(tt.raw); got != tt.detected {
+					t.Errorf("偵測為 %s，應為 %s", got, tt.detected)
+				}
+			}
+			text, enc, err := DecodeText(tt.raw, tt.override)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enc != tt.detected {
+				t.Errorf("使用的編碼為 %s，應為 %s", enc, tt.detected)
+			}
+			if string(text) != encodingFixture {
+				t.Errorf("內容為 %q，應為 %q", text, encodingFixture)
+			}
+		})
+	}
+}
+
+func TestDecodeTextRejectsWrongOverride(t *testing.T) {
+	big5, _ := traditionalchinese.Big5.NewEncoder().Bytes([]byte(encodingFixture))
+	if _, _, err := DecodeText(big5, EncodingUTF8); err == nil {
+		t.Error("Big5 檔案指定為 UTF-8 時應回傳錯誤")
+	}
+	if _, _, err := DecodeText([]byte(encodingFixture), "latin1"); err == nil {
+		t.Error("不支援的編碼應回傳錯誤")
+	}
+}
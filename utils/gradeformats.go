@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 外部系統匯出的成績檔格式
+const (
+	FormatNative    = ""
+	FormatMoodle    = "moodle"
+	FormatClassroom = "classroom"
+)
+
+// GradebookFormatLabel 成績檔格式的顯示名稱
+func GradebookFormatLabel(format string) string {
+	switch format {
+	case FormatMoodle:
+		return "Moodle"
+	case FormatClassroom:
+		return "Google Classroom"
+	}
+	return "一般格式"
+}
+
+// moodleItemHeader Moodle 成績項目的標題，例如「Assignment: HW1 (Real)」
+var moodleItemHeader = regexp.MustCompile(`^(?:[^:]+:\s*)?(.+?)\s*\((Real|Percentage|Letter)\)$`)
+
+// DetectGradebookFormat 依標題列判斷成績檔是否為 Moodle 或 Google Classroom 匯出的格式
+func DetectGradebookFormat(records [][]string) string {
+	if len(records) == 0 {
+		return FormatNative
+	}
+	cols := make(map[string]bool)
+	moodleItems := 0
+	for _, h := range records[0] {
+		name := strings.ToLower(CleanHeader(h))
+		cols[name] = true
+		if moodleItemHeader.MatchString(CleanHeader(h)) {
+			moodleItems++
+		}
+	}
+	switch {
+	case (cols["id number"] || cols["email address"]) && moodleItems > 0:
+		return FormatMoodle
+	case cols["email address"] && cols["last name"] && cols["first name"]:
+		return FormatClassroom
+	}
+	return FormatNative
+}
+
+// NormalizeGradebook 將 Moodle / Google Classroom 的成績檔轉成本系統的格式：
+// 第一欄為學號，其餘每欄一個成績項目，必要時第二列為滿分列。
+// 兩種格式都可能只有 Email，emailToID 用來把已綁定的 Email 換成學號；找不到時保留 Email，預覽時會列為名單外。
+// scheme 用來把只有百分比的 Moodle 欄位換算回分數
+func NormalizeGradebook(format string, records [][]string, emailToID map[string]string, scheme GradingScheme) [][]string {
+	switch format {
+	case FormatMoodle:
+		return normalizeMoodle(records, emailToID, scheme)
+	case FormatClassroom:
+		return normalizeClassroom(records, emailToID)
+	}
+	return records
+}
+
+func findColumn(header []string, names ...string) int {
+	for i, h := range header {
+		name := strings.ToLower(CleanHeader(h))
+		for _, n := range names {
+			if name == n {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func cellAt(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[col])
+}
+
+// studentKey 優先使用學號欄位，沒有時以 Email 對應
+func studentKey(row []string, idCol, emailCol int, emailToID map[string]string) string {
+	if id := CleanID(cellAt(row, idCol)); id != "" {
+		return id
+	}
+	email := strings.ToLower(cellAt(row, emailCol))
+	if id, ok := emailToID[email]; ok {
+		return id
+	}
+	return email
+}
+
+// moodleColumn Moodle 成績項目對應的欄位；percent 代表該欄是百分比，需要依滿分換算
+type moodleColumn struct {
+	col     int
+	percent bool
+}
+
+// normalizeMoodle Moodle 匯出：「ID number」、「Email address」加上「類型: 名稱 (Real)」的成績欄，未評分為「-」。
+// 同一個項目同時有 (Real) 與 (Percentage) 兩欄時只取 (Real)；只有 (Percentage) 時依本系統的滿分換算成分數
+func normalizeMoodle(records [][]string, emailToID map[string]string, scheme GradingScheme) [][]string {
+	header := records[0]
+	idCol := findColumn(header, "id number")
+	emailCol := findColumn(header, "email address")
+
+	out := [][]string{{"ID"}}
+	var items []string
+	columns := make(map[string]moodleColumn)
+	for i, h := range header {
+		m := moodleItemHeader.FindStringSubmatch(CleanHeader(h))
+		// 等第制的欄位無法換算成分數；總分欄位由本系統自行計算
+		if m == nil || m[2] == "Letter" || strings.HasPrefix(strings.ToLower(m[1]), "course total") || strings.HasPrefix(strings.ToLower(m[1]), "category total") {
+			continue
+		}
+		name, percent := m[1], m[2] == "Percentage"
+		prev, seen := columns[name]
+		if !seen {
+			items = append(items, name)
+			out[0] = append(out[0], name)
+		}
+		if !seen || (prev.percent && !percent) {
+			columns[name] = moodleColumn{col: i, percent: percent}
+		}
+	}
+
+	for _, row := range records[1:] {
+		key := studentKey(row, idCol, emailCol, emailToID)
+		if key == "" {
+			continue
+		}
+		record := []string{key}
+		for _, name := range items {
+			column := columns[name]
+			value := strings.TrimSpace(strings.TrimSuffix(cellAt(row, column.col), "%"))
+			if column.percent {
+				if pct, err := strconv.ParseFloat(value, 64); err == nil {
+					value = strconv.FormatFloat(Round2(pct*scheme.MaxPoints(name)/100), 'f', -1, 64)
+				}
+			}
+			record = append(record, value)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+// normalizeClassroom Google Classroom 匯出：姓、名、Email 加上每份作業一欄，
+// 學生資料前有到期日、「Points」(滿分) 與「Class average」等沒有 Email 的說明列
+func normalizeClassroom(records [][]string, emailToID map[string]string) [][]string {
+	header := records[0]
+	emailCol := findColumn(header, "email address")
+	idCol := findColumn(header, "student id", "學號")
+	skip := map[int]bool{emailCol: true, idCol: true, findColumn(header, "last name"): true, findColumn(header, "first name"): true}
+
+	out := [][]string{{"ID"}}
+	var itemCols []int
+	for i, h := range header {
+		if skip[i] || CleanHeader(h) == "" {
+			continue
+		}
+		itemCols = append(itemCols, i)
+		out[0] = append(out[0], CleanHeader(h))
+	}
+
+	var maxRow []string
+	for _, row := range records[1:] {
+		if cellAt(row, emailCol) == "" {
+			if strings.EqualFold(cellAt(row, 0), "points") {
+				maxRow = []string{"Max Points"}
+				for _, col := range itemCols {
+					maxRow = append(maxRow, cellAt(row, col))
+				}
+			}
+			continue
+		}
+		record := []string{studentKey(row, idCol, emailCol, emailToID)}
+		for _, col := range itemCols {
+			record = append(record, cellAt(row, col))
+		}
+		out = append(out, record)
+	}
+	if maxRow != nil {
+		out = append([][]string{out[0], maxRow}, out[1:]...)
+	}
+	return out
+}
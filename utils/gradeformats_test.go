@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"grade-system/models"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeMoodle(t *testing.T) {
+	records := [][]string{
+		{"First name", "Last name", "ID number", "Email address", "Quiz: HW1 (Percentage)", "Quiz: HW1 (Real)", "Assignment: Essay (Percentage)", "Quiz: HW2 (Letter)", "Course total (Real)"},
+		{"小明", "王", "S001", "s001@example.com", "85.00 %", "17.00", "90.00 %", "A", "88.00"},
+		{"小華", "李", "", "S002@Example.com", "-", "-", "45.50 %", "B", "40.00"},
+		{"未綁定", "陳", "", "nobody@example.com", "50.00 %", "10.00", "-", "C", "10.00"},
+	}
+	if got := DetectGradebookFormat(records); got != FormatMoodle {
+		t.Fatalf("偵測為 %q，應為 Moodle", got)
+	}
+	scheme := GradingScheme{Items: map[string]models.GradeItem{"Essay": {ItemName: "Essay", MaxPoints: 20}}}
+	got := NormalizeGradebook(FormatMoodle, records, map[string]string{"s002@example.com": "S002"}, scheme)
+	want := [][]string{
+		{"ID", "HW1", "Essay"},
+		// HW1 同時有 Real 與 Percentage 時只取 Real；Essay 只有百分比，依滿分 20 換算
+		{"S001", "17.00", "18"},
+		{"S002", "-", "9.1"},
+		{"nobody@example.com", "10.00", "-"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("轉換為 %q，應為 %q", got, want)
+	}
+}
+
+func TestNormalizeClassroom(t *testing.T) {
+	records := [][]string{
+		{"Last Name", "First Name", "Email Address", "HW1", "Midterm", ""},
+		{"", "", "", "2024/3/1", "2024/4/1", ""},
+		{"Points", "", "", "20", "100", ""},
+		{"Class average", "", "", "15", "70", ""},
+		{"王", "小明", "s001@example.com", "18", "", ""},
+		{"李", "小華", "s002@example.com", "12", "65", ""},
+	}
+	if got := DetectGradebookFormat(records); got != FormatClassroom {
+		t.Fatalf("偵測為 %q，應為 Google Classroom", got)
+	}
+	got := NormalizeGradebook(FormatClassroom, records, map[string]string{"s001@example.com": "S001"}, GradingScheme{})
+	want := [][]string{
+		{"ID", "HW1", "Midterm"},
+		{"Max Points", "20", "100"},
+		{"S001", "18", ""},
+		{"s002@example.com", "12", "65"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("轉換為 %q，應為 %q", got, want)
+	}
+}
+
+func TestDetectGradebookFormatNative(t *testing.T) {
+	records := [][]string{{"Class", "ID", "Name", "HW1"}, {"A", "S001", "王小明", "80"}}
+	if got := DetectGradebookFormat(records); got != FormatNative {
+		t.Errorf("偵測為 %q，應為一般格式", got)
+	}
+	if got := NormalizeGradebook(FormatNative, records, nil, GradingScheme{}); !reflect.DeepEqual(got, records) {
+		t.Errorf("一般格式不應被改動：%q", got)
+	}
+}
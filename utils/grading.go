@@ -0,0 +1,348 @@
+package utils
+
+import (
+	"grade-system/initializers"
+	"grade-system/models"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxPoints 未設定滿分的項目一律視為 100 分制
+const DefaultMaxPoints = 100.0
+
+// GradingScheme 某科目的評分方式 (分類權重 + 各項目設定)
+type GradingScheme struct {
+	Subject    string
+	Categories []models.GradeCategory
+	Items      map[string]models.GradeItem
+	Letters    LetterScale
+	// CustomLetters 為 false 代表沿用 DefaultLetterCutoffs
+	CustomLetters bool
+}
+
+// ItemResult 單一評量項目換算後的結果
+type ItemResult struct {
+	ItemName     string
+	Category     string
+	Score        float64 // 調分後的分數 (未調分時等於原始分數)
+	RawScore     float64 // 老師輸入的原始分數
+	MaxPoints    float64
+	Percent      float64 // 分數 / 滿分 (%)
+	Contribution float64 // 對學期總成績的貢獻 (已加權)
+	Status       string  // 成績狀態，見 models.GradeScored 等常數
+	Excluded     bool    // 因狀態 (空白、EX 等) 不計入總分
+	Dropped      bool    // 被分類規則排除，不計入總分
+}
+
+// Evaluation 單一學生依評分方式計算的結果
+type Evaluation struct {
+	Items           []ItemResult
+	Total           float64
+	RemainingWeight float64 // 尚未有成績的權重 (%)
+}
+
+// LoadScheme 讀取科目的評分方式
+func LoadScheme(subject string) GradingScheme {
+	scheme := GradingScheme{Subject: subject, Items: make(map[string]models.GradeItem)}
+	initializers.DB.Where("subject = ?", subject).Order("sort_order asc, id asc").Find(&scheme.Categories)
+
+	var items []models.GradeItem
+	initializers.DB.Where("subject = ?", subject).Find(&items)
+	for _, item := range items {
+		scheme.Items[item.ItemName] = item
+	}
+	scheme.Letters, scheme.CustomLetters = LoadLetterScale(subject)
+	return scheme
+}
+
+// Configured 老師是否已經設定過分類；未設定時沿用舊的「期末補足 100%」規則
+func (s GradingScheme) Configured() bool {
+	return len(s.Categories) > 0
+}
+
+// MaxPoints 回傳項目的滿分
+func (s GradingScheme) MaxPoints(itemName string) float64 {
+	if item, ok := s.Items[itemName]; ok && item.MaxPoints > 0 {
+		return item.MaxPoints
+	}
+	return DefaultMaxPoints
+}
+
+// CategoryOf 回傳項目所屬的分類，未歸類時 ok 為 false
+func (s GradingScheme) CategoryOf(itemName string) (models.GradeCategory, bool) {
+	item, ok := s.Items[itemName]
+	if !ok || item.CategoryID == nil {
+		return models.GradeCategory{}, false
+	}
+	for _, cat := range s.Categories {
+		if cat.ID == *item.CategoryID {
+			return cat, true
+		}
+	}
+	return models.GradeCategory{}, false
+}
+
+// Evaluate 依評分方式計算單一學生的成績明細與總分 (grades 需為同一位學生)
+func (s GradingScheme) Evaluate(grades []models.Grade) Evaluation {
+	if !s.Configured() {
+		return s.evaluateLegacy(grades)
+	}
+
+	ev := Evaluation{Items: make([]ItemResult, len(grades))}
+	byCategory := make(map[uint][]int)
+	for i, g := range grades {
+		ev.Items[i] = s.newItemResult(g)
+		cat, ok := s.CategoryOf(g.ItemName)
+		if !ok {
+			continue
+		}
+		ev.Items[i].Category = cat.Name
+		if !countsToward(cat, g.Status) {
+			ev.Items[i].Excluded = true
+			continue
+		}
+		byCategory[cat.ID] = append(byCategory[cat.ID], i)
+	}
+
+	for _, cat := range s.Categories {
+		idx := byCategory[cat.ID]
+		if len(idx) == 0 {
+			ev.RemainingWeight += cat.Weight
+			continue
+		}
+		counted := countedItems(cat, idx, ev.Items)
+		// 分類得分率 = 分類內採計項目得分率的平均，滿分不同的項目因此等重計算
+		for _, i := range idx {
+			if !counted[i] {
+				ev.Items[i].Dropped = true
+				continue
+			}
+			contribution := cat.Weight * (ev.Items[i].Score / ev.Items[i].MaxPoints) / float64(len(counted))
+			ev.Total += contribution
+			ev.Items[i].Contribution = Round2(contribution)
+		}
+	}
+	ev.Total = Round2(ev.Total)
+	return ev
+}
+
+// countsToward 依分類設定判斷非分數狀態的成績是否計入 (計入時以 0 分計)
+func countsToward(cat models.GradeCategory, status string) bool {
+	switch status {
+	case models.GradeMissing:
+		return cat.MissingAsZero
+	case models.GradeExcused:
+		return false
+	case models.GradeAbsent:
+		return !cat.ExcuseAbsent
+	}
+	return true
+}
+
+// countedItems 套用分類的「去掉最低 N 次 / 取最佳 M 次」規則，回傳要採計的項目 (至少保留一項)
+func countedItems(cat models.GradeCategory, idx []int, items []ItemResult) map[int]bool {
+	sorted := make([]int, len(idx))
+	copy(sorted, idx)
+	// 依得分率由高到低排序，同分時保留較早的項目
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return items[sorted[a]].Score/items[sorted[a]].MaxPoints > items[sorted[b]].Score/items[sorted[b]].MaxPoints
+	})
+
+	keep := len(sorted)
+	if cat.BestOf > 0 {
+		keep = min(keep, cat.BestOf)
+	} else if cat.DropLowest > 0 {
+		keep -= cat.DropLowest
+	}
+	keep = max(keep, 1)
+
+	counted := make(map[int]bool, keep)
+	for _, i := range sorted[:keep] {
+		counted[i] = true
+	}
+	return counted
+}
+
+func (s GradingScheme) newItemResult(g models.Grade) ItemResult {
+	maxPoints := s.MaxPoints(g.ItemName)
+	score := 0.0
+	if g.Status == models.GradeScored {
+		score = s.EffectiveScore(g.ItemName, g.Score)
+	}
+	return ItemResult{
+		ItemName:  g.ItemName,
+		Score:     score,
+		RawScore:  g.Score,
+		MaxPoints: maxPoints,
+		Percent:   Percent(score, maxPoints),
+		Status:    g.Status,
+	}
+}
+
+// evaluateLegacy 舊規則：名為 Final/期末考 的項目佔比 = 100 - 其餘項目總和；
+// 每個項目先換算成 100 分制再加總，滿分不是 100 的項目不會多算或少算
+func (s GradingScheme) evaluateLegacy(grades []models.Grade) Evaluation {
+	ev := Evaluation{Items: make([]ItemResult, len(grades))}
+	preFinal := 0.0
+	finalIdx := -1
+	for i, g := range grades {
+		ev.Items[i] = s.newItemResult(g)
+		normalized := ev.Items[i].Score / ev.Items[i].MaxPoints * 100
+		ev.Items[i].Contribution = Round2(normalized)
+		ev.Items[i].Excluded = g.Status == models.GradeMissing || g.Status == models.GradeExcused
+		if IsLegacyFinal(g.ItemName) {
+			finalIdx = i
+		} else {
+			preFinal += normalized
+		}
+	}
+
+	ev.RemainingWeight = math.Max(0, 100.0-preFinal)
+	ev.Total = preFinal
+	if finalIdx != -1 {
+		final := ev.Items[finalIdx]
+		weighted := final.Score / final.MaxPoints * 100 * (ev.RemainingWeight / 100.0)
+		ev.Items[finalIdx].Category = "期末"
+		ev.Items[finalIdx].Contribution = Round2(weighted)
+		ev.Total += weighted
+	}
+	ev.Total = Round2(ev.Total)
+	return ev
+}
+
+// ClassTotals 依評分方式計算全班每位學生的總分 (key: 學號)
+func (s GradingScheme) ClassTotals(grades []models.Grade) map[string]float64 {
+	byStudent := make(map[string][]models.Grade)
+	for _, g := range grades {
+		byStudent[g.StudentID] = append(byStudent[g.StudentID], g)
+	}
+	totals := make(map[string]float64, len(byStudent))
+	for sid, list := range byStudent {
+		totals[sid] = s.Evaluate(list).Total
+	}
+	return totals
+}
+
+// ItemStats 單一評量項目的全班統計 (以得分率計，不受滿分不同影響)
+type ItemStats struct {
+	ItemName      string
+	MaxPoints     float64
+	Count         int
+	MeanPercent   float64
+	StdDevPercent float64
+}
+
+// ItemStatistics 計算每個評量項目的平均得分率與標準差，依項目名稱排序
+func (s GradingScheme) ItemStatistics(grades []models.Grade) []ItemStats {
+	percents := make(map[string][]float64)
+	for _, g := range grades {
+		// 空白與 EX 不列入項目統計，缺考以 0 分計
+		if g.Status == models.GradeMissing || g.Status == models.GradeExcused {
+			continue
+		}
+		score := 0.0
+		if g.Status == models.GradeScored {
+			score = s.EffectiveScore(g.ItemName, g.Score)
+		}
+		percents[g.ItemName] = append(percents[g.ItemName], Percent(score, s.MaxPoints(g.ItemName)))
+	}
+
+	stats := make([]ItemStats, 0, len(percents))
+	for name, list := range percents {
+		st := ItemStats{ItemName: name, MaxPoints: s.MaxPoints(name), Count: len(list)}
+		sum := 0.0
+		for _, p := range list {
+			sum += p
+		}
+		st.MeanPercent = sum / float64(st.Count)
+		varianceSum := 0.0
+		for _, p := range list {
+			varianceSum += math.Pow(p-st.MeanPercent, 2)
+		}
+		st.StdDevPercent = math.Sqrt(varianceSum / float64(st.Count))
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ItemName < stats[j].ItemName })
+	return stats
+}
+
+// IsLegacyFinal 判斷是否為舊規則中的期末考項目
+func IsLegacyFinal(itemName string) bool {
+	return strings.EqualFold(itemName, "Final") || strings.EqualFold(itemName, "期末考")
+}
+
+// ClassStats 全班總分的統計資料
+type ClassStats struct {
+	Count  int
+	Mean   float64
+	StdDev float64
+	Min    float64
+	Max    float64
+	Top3   []float64
+	sorted []float64
+}
+
+// ComputeClassStats 計算平均、標準差、最高最低與前三名
+func ComputeClassStats(totals map[string]float64) ClassStats {
+	st := ClassStats{Count: len(totals)}
+	for _, t := range totals {
+		st.sorted = append(st.sorted, t)
+	}
+	if st.Count == 0 {
+		return st
+	}
+	sort.Float64s(st.sorted)
+
+	sum := 0.0
+	for _, t := range st.sorted {
+		sum += t
+	}
+	st.Mean = sum / float64(st.Count)
+
+	varianceSum := 0.0
+	for _, t := range st.sorted {
+		varianceSum += math.Pow(t-st.Mean, 2)
+	}
+	st.StdDev = math.Sqrt(varianceSum / float64(st.Count))
+	st.Min = st.sorted[0]
+	st.Max = st.sorted[st.Count-1]
+
+	for i := st.Count - 1; i >= 0 && len(st.Top3) < 3; i-- {
+		st.Top3 = append(st.Top3, st.sorted[i])
+	}
+	return st
+}
+
+// Percentile 回傳分數在全班中的 PR 值 (0~99)
+func (st ClassStats) Percentile(score float64) int {
+	if st.Count <= 1 {
+		return 99
+	}
+	rank := 0
+	for i, t := range st.sorted {
+		if t >= score {
+			rank = i
+			break
+		}
+		rank = i + 1
+	}
+	p := int(math.Floor((float64(rank) / float64(st.Count)) * 100))
+	if p > 99 {
+		p = 99
+	}
+	return p
+}
+
+// Percent 將原始分數換算成得分率 (%)
+func Percent(score, maxPoints float64) float64 {
+	if maxPoints <= 0 {
+		maxPoints = DefaultMaxPoints
+	}
+	return Round2(score / maxPoints * 100)
+}
+
+// Round2 四捨五入到小數第二位
+func Round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
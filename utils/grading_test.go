@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"grade-system/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// testScheme 建立評分方式：categories 的 ID 依序為 1、2…，items 為「項目 → (分類 ID, 滿分)」，分類 ID 0 代表未歸類
+func testScheme(categories []models.GradeCategory, items map[string][2]float64) GradingScheme {
+	s := GradingScheme{Items: make(map[string]models.GradeItem)}
+	for i, cat := range categories {
+		cat.Model = gorm.Model{ID: uint(i + 1)}
+		s.Categories = append(s.Categories, cat)
+	}
+	for name, spec := range items {
+		item := models.GradeItem{ItemName: name, MaxPoints: spec[1]}
+		if spec[0] > 0 {
+			id := uint(spec[0])
+			item.CategoryID = &id
+		}
+		s.Items[name] = item
+	}
+	return s
+}
+
+func scored(item string, score float64) models.Grade {
+	return models.Grade{ItemName: item, Score: score}
+}
+
+func withStatus(item, status string) models.Grade {
+	return models.Grade{ItemName: item, Status: status}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name      string
+		scheme    GradingScheme
+		grades    []models.Grade
+		total     float64
+		remaining float64
+		dropped   []string
+		excluded  []string
+	}{
+		{
+			name: "分類權重與不同滿分",
+			scheme: testScheme([]models.GradeCategory{{Name: "作業", Weight: 40}, {Name: "考試", Weight: 60}},
+				map[string][2]float64{"HW1": {1, 20}, "HW2": {1, 100}, "Exam": {2, 50}}),
+			// 作業：(15/20 + 90/100) / 2 = 82.5% → 33；考試：40/50 = 80% → 48
+			grades: []models.Grade{scored("HW1", 15), scored("HW2", 90), scored("Exam", 40)},
+			total:  81,
+		},
+		{
+			name: "沒有成績的分類列為剩餘權重",
+			scheme: testScheme([]models.GradeCategory{{Name: "作業", Weight: 40}, {Name: "考試", Weight: 60}},
+				map[string][2]float64{"HW1": {1, 100}}),
+			grades:    []models.Grade{scored("HW1", 50)},
+			total:     20,
+			remaining: 60,
+		},
+		{
+			name: "未歸類的項目不計入",
+			scheme: testScheme([]models.GradeCategory{{Name: "作業", Weight: 100}},
+				map[string][2]float64{"HW1": {1, 100}, "Bonus": {0, 100}}),
+			grades: []models.Grade{scored("HW1", 70), scored("Bonus", 100)},
+			total:  70,
+		},
+		{
+			name: "去掉最低一次",
+			scheme: testScheme([]models.GradeCategory{{Name: "小考", Weight: 100, DropLowest: 1}},
+				map[string][2]float64{"Q1": {1, 10}, "Q2": {1, 10}, "Q3": {1, 10}}),
+			grades:  []models.Grade{scored("Q1", 4), scored("Q2", 8), scored("Q3", 10)},
+			total:   90,
+			dropped: []string{"Q1"},
+		},
+		{
+			name: "取最佳一次時優先於去掉最低",
+			scheme: testScheme([]models.GradeCategory{{Name: "小考", Weight: 100, DropLowest: 1, BestOf: 1}},
+				map[string][2]float64{"Q1": {1, 10}, "Q2": {1, 10}, "Q3": {1, 10}}),
+			grades:  []models.Grade{scored("Q1", 4), scored("Q2", 8), scored("Q3", 6)},
+			total:   80,
+			dropped: []string{"Q1", "Q3"},
+		},
+		{
+			name: "去掉的次數不超過項目數，至少保留一項",
+			scheme: testScheme([]models.GradeCategory{{Name: "小考", Weight: 100, DropLowest: 3}},
+				map[string][2]float64{"Q1": {1, 10}, "Q2": {1, 10}}),
+			grades:  []models.Grade{scored("Q1", 4), scored("Q2", 8)},
+			total:   80,
+			dropped: []string{"Q1"},
+		},
+		{
+			name: "同分時保留較早的項目",
+			scheme: testScheme([]models.GradeCategory{{Name: "小考", Weight: 100, BestOf: 1}},
+				map[string][2]float64{"Q1": {1, 10}, "Q2": {1, 20}}),
+			grades:  []models.Grade{scored("Q1", 5), scored("Q2", 10)},
+			total:   50,
+			dropped: []string{"Q2"},
+		},
+		{
+			name: "EX 與預設的空白不計入，缺考以 0 分計",
+			scheme: testScheme([]models.GradeCategory{{Name: "小考", Weight: 100}},
+				map[string][2]float64{"Q1": {1, 100}, "Q2": {1, 100}, "Q3": {1, 100}, "Q4": {1, 100}}),
+			grades:   []models.Grade{scored("Q1", 80), withStatus("Q2", models.GradeExcused), withStatus("Q3", models.GradeMissing), withStatus("Q4", models.GradeAbsent)},
+			total:    40,
+			excluded: []string{"Q2", "Q3"},
+		},
+		{
+			name: "空白以 0 分計、缺考不計入",
+			scheme: testScheme([]models.GradeCategory{{Name: "小考", Weight: 100, MissingAsZero: true, ExcuseAbsent: true}},
+				map[string][2]float64{"Q1": {1, 100}, "Q2": {1, 100}, "Q3": {1, 100}}),
+			grades:   []models.Grade{scored("Q1", 80), withStatus("Q2", models.GradeMissing), withStatus("Q3", models.GradeAbsent)},
+			total:    40,
+			excluded: []string{"Q3"},
+		},
+		{
+			name: "被排除的狀態不佔去掉最低的名額",
+			scheme: testScheme([]models.GradeCategory{{Name: "小考", Weight: 100, DropLowest: 1}},
+				map[string][2]float64{"Q1": {1, 100}, "Q2": {1, 100}, "Q3": {1, 100}}),
+			grades:   []models.Grade{scored("Q1", 60), scored("Q2", 90), withStatus("Q3", models.GradeExcused)},
+			total:    90,
+			dropped:  []string{"Q1"},
+			excluded: []string{"Q3"},
+		},
+		{
+			name:   "舊規則：期末補足其餘項目以外的比例",
+			scheme: testScheme(nil, nil),
+			// 平時 20 + 30 = 50，期末 80 分佔 50% → 40
+			grades:    []models.Grade{scored("HW", 20), scored("Mid", 30), scored("Final", 80)},
+			total:     90,
+			remaining: 50,
+		},
+		{
+			name:   "舊規則：依滿分換算成 100 分制再加總",
+			scheme: testScheme(nil, map[string][2]float64{"Quiz": {0, 20}, "期末考": {0, 50}}),
+			// 小考 17/20 = 85，期末 40/50 = 80 分佔 15% → 12
+			grades:    []models.Grade{scored("Quiz", 17), scored("期末考", 40)},
+			total:     97,
+			remaining: 15,
+		},
+		{
+			name:      "舊規則：其餘項目超過 100 時期末不再加分",
+			scheme:    testScheme(nil, nil),
+			grades:    []models.Grade{scored("HW", 70), scored("Mid", 40), scored("final", 100)},
+			total:     110,
+			remaining: 0,
+		},
+		{
+			name:     "舊規則：非分數狀態以 0 分計",
+			scheme:   testScheme(nil, nil),
+			grades:   []models.Grade{scored("HW", 30), withStatus("Mid", models.GradeExcused), withStatus("Lab", models.GradeAbsent)},
+			total:    30,
+			excluded: []string{"Mid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := tt.scheme.Evaluate(tt.grades)
+			if ev.Total != tt.total {
+				t.Errorf("總分為 %g，應為 %g", ev.Total, tt.total)
+			}
+			if tt.remaining != 0 && ev.RemainingWeight != tt.remaining {
+				t.Errorf("剩餘權重為 %g，應為 %g", ev.RemainingWeight, tt.remaining)
+			}
+			dropped, excluded := make(map[string]bool), make(map[string]bool)
+			for _, name := range tt.dropped {
+				dropped[name] = true
+			}
+			for _, name := range tt.excluded {
+				excluded[name] = true
+			}
+			for _, item := range ev.Items {
+				if item.Dropped != dropped[item.ItemName] {
+					t.Errorf("%s 的 Dropped 為 %v", item.ItemName, item.Dropped)
+				}
+				if item.Excluded != excluded[item.ItemName] {
+					t.Errorf("%s 的 Excluded 為 %v", item.ItemName, item.Excluded)
+				}
+			}
+		})
+	}
+}
+
+func TestClassTotals(t *testing.T) {
+	scheme := testScheme([]models.GradeCategory{{Name: "考試", Weight: 100}}, map[string][2]float64{"Exam": {1, 50}})
+	grades := []models.Grade{
+		{StudentID: "s1", ItemName: "Exam", Score: 45},
+		{StudentID: "s2", ItemName: "Exam", Score: 25},
+		{StudentID: "s3", ItemName: "Exam", Status: models.GradeAbsent},
+	}
+	totals := scheme.ClassTotals(grades)
+	want := map[string]float64{"s1": 90, "s2": 50, "s3": 0}
+	for sid, total := range want {
+		if totals[sid] != total {
+			t.Errorf("%s 的總分為 %g，應為 %g", sid, totals[sid], total)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct{ score, max, want float64 }{
+		{17, 20, 85},
+		{1, 3, 33.33},
+		{50, 0, 50}, // 沒有滿分時以 100 分計
+		{0, 50, 0},
+	}
+	for _, tt := range tests {
+		if got := Percent(tt.score, tt.max); got != tt.want {
+			t.Errorf("Percent(%g, %g) = %g，應為 %g", tt.score, tt.max, got, tt.want)
+		}
+	}
+}
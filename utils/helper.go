@@ -1,8 +1,8 @@
 package utils
 
 import (
-	"grade-system/initializers"
-	"os"
+	"grade-system/models"
+	"strconv"
 	"strings"
 
 	"gorm.io/gorm"
@@ -20,21 +20,135 @@ func CleanID(id string) string {
 	return strings.TrimSpace(id)
 }
 
+// IsMaxPointsLabel 判斷 CSV 標題下方那一列是否為「滿分」列
+func IsMaxPointsLabel(cell string) bool {
+	switch strings.ToLower(CleanID(cell)) {
+	case "max points", "max_points", "max", "滿分":
+		return true
+	}
+	return false
+}
+
+// ParseGradeCell 解析成績儲存格：數字為分數，空白或「-」為未登錄，EX 為免計，ABS/缺考為缺考
+func ParseGradeCell(cell string) (float64, string, bool) {
+	value := strings.TrimSpace(cell)
+	switch strings.ToLower(value) {
+	case "", "-":
+		return 0, models.GradeMissing, true
+	case "ex", "excused", "免考", "免計":
+		return 0, models.GradeExcused, true
+	case "abs", "absent", "缺考", "缺":
+		return 0, models.GradeAbsent, true
+	}
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return score, models.GradeScored, true
+}
+
+// GradeStatusLabel 成績狀態的中文說明
+func GradeStatusLabel(status string) string {
+	switch status {
+	case models.GradeMissing:
+		return "未登錄"
+	case models.GradeExcused:
+		return "免計 (EX)"
+	case models.GradeAbsent:
+		return "缺考"
+	}
+	return ""
+}
+
+// GradeStatusToken 匯出檔中代表成績狀態的文字，可再由 ParseGradeCell 讀回
+func GradeStatusToken(status string) string {
+	switch status {
+	case models.GradeExcused:
+		return "EX"
+	case models.GradeAbsent:
+		return "ABS"
+	case models.GradeMissing:
+		return "-"
+	}
+	return ""
+}
+
+// HistorySourceLabel 成績變動來源的中文說明
+func HistorySourceLabel(source string) string {
+	switch source {
+	case models.HistorySourceCSV:
+		return "CSV 匯入"
+	case models.HistorySourceManual:
+		return "手動登錄"
+	case models.HistorySourceCurve:
+		return "調分"
+	case models.HistorySourceRollback:
+		return "復原匯入"
+	case models.HistorySourceRosterSync:
+		return "名單同步"
+	case models.HistorySourceTrash:
+		return "回收桶還原"
+	}
+	return source
+}
+
+// RosterStatusLabel 名單狀態的中文說明
+func RosterStatusLabel(status string) string {
+	switch status {
+	case models.RosterActive:
+		return "修課中"
+	case models.RosterWithdrawn:
+		return "退選"
+	case models.RosterAudit:
+		return "旁聽"
+	case models.RosterIncomplete:
+		return "未完成 (I)"
+	case models.RosterArchived:
+		return "封存"
+	}
+	return status
+}
+
+// RosterStatuses 教師後台可選的名單狀態，依顯示順序排列
+var RosterStatuses = []string{models.RosterActive, models.RosterWithdrawn, models.RosterAudit, models.RosterIncomplete, models.RosterArchived}
+
+// RosterEnrolled 學生是否仍在課堂上 (修課中、旁聽或未完成)；退選與封存的學生在名單同步時不再列為缺少
+func RosterEnrolled(status string) bool {
+	return status != models.RosterWithdrawn && status != models.RosterArchived
+}
+
+// ImportKindLabel 匯入檔案種類的中文說明
+func ImportKindLabel(kind string) string {
+	switch kind {
+	case models.ImportKindGrades:
+		return "成績"
+	case models.ImportKindRoster:
+		return "名單"
+	}
+	return kind
+}
+
+// HistoryActionLabel 成績變動類型的中文說明
+func HistoryActionLabel(action string) string {
+	switch action {
+	case models.HistoryActionCreate:
+		return "新增"
+	case models.HistoryActionUpdate:
+		return "修改"
+	case models.HistoryActionDelete:
+		return "刪除"
+	}
+	return action
+}
+
 // Inc 樣板用的加法函式
 func Inc(i int) int {
 	return i + 1
 }
 
-// IsTeacher 檢查是否為老師
-func IsTeacher(email string) bool {
-	whitelist := os.Getenv("TEACHER_WHITELIST")
-	return strings.Contains(whitelist, email)
-}
-
-// FilterSubject GORM Scope: 自動過濾科目
-func FilterSubject(db *gorm.DB) *gorm.DB {
-	if initializers.CurrentSubject != "" {
-		return db.Where("subject = ?", initializers.CurrentSubject)
+// FilterSubject GORM Scope: 只查某門課程 (由請求網址決定) 的資料
+func FilterSubject(subject string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("subject = ?", subject)
 	}
-	return db
 }
\ No newline at end of file
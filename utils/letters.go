@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"fmt"
+	"grade-system/initializers"
+	"grade-system/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLetterCutoffs 科目未自訂時使用的等第對照表 (4.3 制)
+var DefaultLetterCutoffs = []models.LetterCutoff{
+	{Letter: "A+", MinScore: 90, GPA: 4.3},
+	{Letter: "A", MinScore: 85, GPA: 4.0},
+	{Letter: "A-", MinScore: 80, GPA: 3.7},
+	{Letter: "B+", MinScore: 77, GPA: 3.3},
+	{Letter: "B", MinScore: 73, GPA: 3.0},
+	{Letter: "B-", MinScore: 70, GPA: 2.7},
+	{Letter: "C+", MinScore: 67, GPA: 2.3},
+	{Letter: "C", MinScore: 63, GPA: 2.0},
+	{Letter: "C-", MinScore: 60, GPA: 1.7},
+	{Letter: "F", MinScore: 0, GPA: 0},
+}
+
+// LetterScale 依 MinScore 由高到低排序的等第對照表
+type LetterScale []models.LetterCutoff
+
+// LetterCount 等第分布中的一格
+type LetterCount struct {
+	Letter string
+	Count  int
+}
+
+// LoadLetterScale 讀取科目自訂的等第對照表，沒有設定時回傳預設表
+func LoadLetterScale(subject string) (LetterScale, bool) {
+	var cutoffs []models.LetterCutoff
+	initializers.DB.Where("subject = ?", subject).Find(&cutoffs)
+	if len(cutoffs) == 0 {
+		return NewLetterScale(DefaultLetterCutoffs), false
+	}
+	return NewLetterScale(cutoffs), true
+}
+
+// NewLetterScale 複製並排序對照表
+func NewLetterScale(cutoffs []models.LetterCutoff) LetterScale {
+	scale := make(LetterScale, len(cutoffs))
+	copy(scale, cutoffs)
+	sort.SliceStable(scale, func(i, j int) bool { return scale[i].MinScore > scale[j].MinScore })
+	return scale
+}
+
+// Lookup 依總分回傳對應的等第；低於所有門檻時回傳最低的一列
+func (ls LetterScale) Lookup(total float64) models.LetterCutoff {
+	for _, cut := range ls {
+		if total >= cut.MinScore {
+			return cut
+		}
+	}
+	if len(ls) == 0 {
+		return models.LetterCutoff{}
+	}
+	return ls[len(ls)-1]
+}
+
+// Distribution 統計全班各等第人數，順序與對照表相同
+func (ls LetterScale) Distribution(totals map[string]float64) []LetterCount {
+	counts := make(map[string]int)
+	for _, t := range totals {
+		counts[ls.Lookup(t).Letter]++
+	}
+	dist := make([]LetterCount, 0, len(ls))
+	for _, cut := range ls {
+		dist = append(dist, LetterCount{Letter: cut.Letter, Count: counts[cut.Letter]})
+	}
+	return dist
+}
+
+// String 轉成老師編輯用的文字格式，每行「等第 最低分 GPA」
+func (ls LetterScale) String() string {
+	var sb strings.Builder
+	for _, cut := range ls {
+		fmt.Fprintf(&sb, "%s %g %g\n", cut.Letter, cut.MinScore, cut.GPA)
+	}
+	return sb.String()
+}
+
+// ParseLetterScale 解析老師輸入的對照表文字，每行「等第 最低分 GPA」
+func ParseLetterScale(text string) (LetterScale, error) {
+	var cutoffs []models.LetterCutoff
+	seen := make(map[string]bool)
+	for n, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("第 %d 行格式錯誤，應為「等第 最低分 GPA」", n+1)
+		}
+		minScore, err1 := strconv.ParseFloat(fields[1], 64)
+		gpa, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("第 %d 行的分數或 GPA 不是數字", n+1)
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("第 %d 行的等第 %s 重複", n+1, fields[0])
+		}
+		seen[fields[0]] = true
+		cutoffs = append(cutoffs, models.LetterCutoff{Letter: fields[0], MinScore: minScore, GPA: gpa})
+	}
+	return NewLetterScale(cutoffs), nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLetterScale(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		letters string // 依最低分由高到低排列的等第
+		wantErr string
+	}{
+		{name: "預設表來回轉換", text: NewLetterScale(DefaultLetterCutoffs).String(), letters: "A+ A A- B+ B B- C+ C C- F"},
+		{name: "空白行與順序", text: "\nF 0 0\n\n  A 80 4  \nB 70 3\n", letters: "A B F"},
+		{name: "欄位數不對", text: "A 80 4\nB 70\n", wantErr: "第 2 行格式錯誤"},
+		{name: "分數不是數字", text: "A eighty 4\n", wantErr: "第 1 行的分數或 GPA 不是數字"},
+		{name: "GPA 不是數字", text: "A 80 x\n", wantErr: "第 1 行的分數或 GPA 不是數字"},
+		{name: "等第重複", text: "A 80 4\nA 70 3\n", wantErr: "第 2 行的等第 A 重複"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scale, err := ParseLetterScale(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("錯誤為 %v，應包含「%s」", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var letters []string
+			for _, cut := range scale {
+				letters = append(letters, cut.Letter)
+			}
+			if got := strings.Join(letters, " "); got != tt.letters {
+				t.Errorf("等第順序為 %q，應為 %q", got, tt.letters)
+			}
+		})
+	}
+}
+
+func TestLetterScaleLookup(t *testing.T) {
+	scale := NewLetterScale(DefaultLetterCutoffs)
+	tests := []struct {
+		total  float64
+		letter string
+		gpa    float64
+	}{
+		{100, "A+", 4.3},
+		{90, "A+", 4.3},
+		{89.99, "A", 4.0},
+		{60, "C-", 1.7},
+		{59.5, "F", 0},
+		{-5, "F", 0},
+	}
+	for _, tt := range tests {
+		if cut := scale.Lookup(tt.total); cut.Letter != tt.letter || cut.GPA != tt.gpa {
+			t.Errorf("Lookup(%g) = %s (%g)，應為 %s (%g)", tt.total, cut.Letter, cut.GPA, tt.letter, tt.gpa)
+		}
+	}
+	if cut := (LetterScale{}).Lookup(80); cut.Letter != "" {
+		t.Errorf("空的對照表應回傳空白等第，得到 %q", cut.Letter)
+	}
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"grade-system/initializers"
+	"grade-system/models"
+	"os"
+	"slices"
+	"strings"
+)
+
+// StaffRoles 可指派給課程人員的角色，依權限由大到小排列
+var StaffRoles = []string{models.RoleOwner, models.RoleCoTeacher, models.RoleTAGrader, models.RoleTAReader}
+
+// StaffRoleLabel 課程角色的顯示名稱
+func StaffRoleLabel(role string) string {
+	switch role {
+	case models.RoleOwner:
+		return "授課老師"
+	case models.RoleCoTeacher:
+		return "協同教學"
+	case models.RoleTAGrader:
+		return "助教 (登錄成績)"
+	case models.RoleTAReader:
+		return "助教 (唯讀)"
+	case models.RoleAdmin:
+		return "管理員"
+	}
+	return role
+}
+
+// StaffPermissions 課程角色可以做的事
+type StaffPermissions struct {
+	Role   string
+	Grade  bool     // 上傳與登錄成績
+	Manage bool     // 名單、評分方式、調分、復原匯入與回收桶還原
+	Owner  bool     // 清空名單、清空成績與永久刪除
+	Review bool     // 助教：只能登錄 Items 中的項目，寫入的成績要等老師確認
+	Items  []string // 助教負責的評量項目或分類名稱
+}
+
+// PermissionsFor 角色對應的權限；沒有角色時全部為 false
+func PermissionsFor(role string) StaffPermissions {
+	p := StaffPermissions{Role: role}
+	switch role {
+	case models.RoleAdmin, models.RoleOwner:
+		p.Grade, p.Manage, p.Owner = true, true, true
+	case models.RoleCoTeacher:
+		p.Grade, p.Manage = true, true
+	case models.RoleTAGrader:
+		p.Grade, p.Review = true, true
+	}
+	return p
+}
+
+// CanGrade 是否可以登錄某個評量項目的成績；助教只能登錄指派給他的項目或分類中的項目 (名稱須完全相同，與成績的唯一索引一致)
+func (p StaffPermissions) CanGrade(scheme GradingScheme, itemName string) bool {
+	if !p.Grade || !p.Review {
+		return p.Grade
+	}
+	cat, hasCat := scheme.CategoryOf(itemName)
+	for _, assigned := range p.Items {
+		if assigned == itemName || (hasCat && assigned == cat.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitItems 將逗號分隔的清單 (評量項目、分類名稱或 Email) 拆開，去掉空白與重複
+func SplitItems(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// IsAdmin 全站管理員：ADMIN_WHITELIST 中的 Email (逗號分隔，須完全相符) 或資料庫中 Subject 為空白的管理員
+func IsAdmin(email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, allowed := range AdminWhitelist() {
+		if strings.EqualFold(allowed, email) {
+			return true
+		}
+	}
+	var count int64
+	initializers.DB.Model(&models.CourseStaff{}).Where("subject = '' AND LOWER(email) = LOWER(?) AND role = ?", email, models.RoleAdmin).Count(&count)
+	return count > 0
+}
+
+// AdminWhitelist ADMIN_WHITELIST 中的管理員 Email；舊版的 TEACHER_WHITELIST 只在升級時轉成 APP_SUBJECT 的授課老師
+func AdminWhitelist() []string {
+	return SplitItems(os.Getenv("ADMIN_WHITELIST"))
+}
+
+// CourseRole 某個 Email 在課程中的角色；管理員在每門課程都是 admin，不是課程人員時為空字串
+func CourseRole(email, subject string) string {
+	return courseStaff(email, subject).Role
+}
+
+// CoursePermissions 某個 Email 在課程中的權限，包含助教負責的項目
+func CoursePermissions(email, subject string) StaffPermissions {
+	staff := courseStaff(email, subject)
+	p := PermissionsFor(staff.Role)
+	if p.Review {
+		p.Items = SplitItems(staff.Items)
+	}
+	return p
+}
+
+func courseStaff(email, subject string) models.CourseStaff {
+	var staff models.CourseStaff
+	if IsAdmin(email) {
+		staff.Role = models.RoleAdmin
+		return staff
+	}
+	if email == "" || subject == "" {
+		return staff
+	}
+	initializers.DB.Where("subject = ? AND LOWER(email) = LOWER(?)", subject, email).First(&staff)
+	return staff
+}
@@ -0,0 +1,323 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// XLSXContentType .xlsx 下載時的 Content-Type
+const XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// XLSXBook 已開啟的 .xlsx 活頁簿，只支援讀取儲存格的值
+type XLSXBook struct {
+	sheets []xlsxSheet
+	shared []string
+	files  map[string]*zip.File
+}
+
+type xlsxSheet struct {
+	Name string
+	Path string
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.R) == 0 {
+		return t.T
+	}
+	var sb strings.Builder
+	for _, r := range t.R {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+type xlsxSST struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Index int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// IsXLSX 以檔頭判斷是否為 .xlsx (zip) 檔
+func IsXLSX(raw []byte) bool {
+	return bytes.HasPrefix(raw, []byte("PK\x03\x04"))
+}
+
+// OpenXLSX 解析 .xlsx 的工作表清單與共用字串
+func OpenXLSX(raw []byte) (*XLSXBook, error) {
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, errors.New("無法開啟 Excel 檔案")
+	}
+	book := &XLSXBook{files: make(map[string]*zip.File)}
+	for _, f := range zr.File {
+		book.files[f.Name] = f
+	}
+
+	var wb xlsxWorkbook
+	if err := book.decode("xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	var rels xlsxRels
+	if err := book.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, rel := range rels.Rels {
+		// Target 可能是相對於 xl/ 的路徑，也可能是從根目錄開始的絕對路徑
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	for _, s := range wb.Sheets {
+		if p, ok := targets[s.RID]; ok {
+			book.sheets = append(book.sheets, xlsxSheet{Name: s.Name, Path: p})
+		}
+	}
+	if len(book.sheets) == 0 {
+		return nil, errors.New("Excel 檔案中沒有工作表")
+	}
+
+	if _, ok := book.files["xl/sharedStrings.xml"]; ok {
+		var sst xlsxSST
+		if err := book.decode("xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			book.shared = append(book.shared, si.String())
+		}
+	}
+	return book, nil
+}
+
+func (b *XLSXBook) decode(name string, v interface{}) error {
+	f, ok := b.files[name]
+	if !ok {
+		return fmt.Errorf("Excel 檔案缺少 %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("Excel 檔案的 %s 格式錯誤", name)
+	}
+	return nil
+}
+
+// SheetNames 依活頁簿中的順序回傳工作表名稱
+func (b *XLSXBook) SheetNames() []string {
+	names := make([]string, len(b.sheets))
+	for i, s := range b.sheets {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// ReadSheet 將工作表讀成二維表格，空白儲存格補成空字串；標題列之前的空白列會略過，
+// firstRow 為標題列在 Excel 中的列號，錯誤訊息以此換算出與 Excel 相同的列號
+func (b *XLSXBook) ReadSheet(name string) (records [][]string, firstRow int, err error) {
+	sheetPath := ""
+	for _, s := range b.sheets {
+		if s.Name == name {
+			sheetPath = s.Path
+		}
+	}
+	if sheetPath == "" {
+		return nil, 0, fmt.Errorf("找不到工作表「%s」", name)
+	}
+
+	var ws xlsxWorksheet
+	if err := b.decode(sheetPath, &ws); err != nil {
+		return nil, 0, err
+	}
+	records = make([][]string, 0, len(ws.Rows))
+	firstRow = 1
+	for _, row := range ws.Rows {
+		// 完全空白的列不會出現在檔案中，標題列之後補回來讓列號與 Excel 一致
+		for row.Index > 0 && len(records) > 0 && firstRow+len(records) < row.Index {
+			records = append(records, nil)
+		}
+		var record []string
+		for _, cell := range row.Cells {
+			col := len(record)
+			if cell.Ref != "" {
+				col = xlsxColumnIndex(cell.Ref)
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			switch cell.Type {
+			case "s":
+				if i, err := strconv.Atoi(cell.Value); err == nil && i >= 0 && i < len(b.shared) {
+					record[col] = b.shared[i]
+				}
+			case "inlineStr":
+				record[col] = cell.Inline.String()
+			default:
+				record[col] = cell.Value
+			}
+		}
+		if len(records) == 0 {
+			if isBlankRecord(record) {
+				firstRow++
+				continue
+			}
+			if row.Index > 0 {
+				firstRow = row.Index
+			}
+		}
+		records = append(records, record)
+	}
+	return records, firstRow, nil
+}
+
+// isBlankRecord 整列都是空白儲存格
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// xlsxColumnIndex 將儲存格位置 (例如 "AB12") 的欄位字母換算成從 0 開始的欄號
+func xlsxColumnIndex(ref string) int {
+	col := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+	}
+	return col - 1
+}
+
+// xlsxColumnName 將從 0 開始的欄號換算成欄位字母
+func xlsxColumnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// WriteXLSX 寫出只有一個工作表的 .xlsx；float64 / int 寫成數字，其餘寫成文字，nil 為空白儲存格
+func WriteXLSX(w io.Writer, sheetName string, rows [][]interface{}) error {
+	zw := zip.NewWriter(w)
+	files := []struct{ Name, Body string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + xlsxEscape(xlsxSheetName(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", xlsxSheetXML(rows)},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.Body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func xlsxSheetXML(rows [][]interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range rows {
+		fmt.Fprintf(&sb, `<row r="%d">`, r+1)
+		for col, v := range row {
+			ref := xlsxColumnName(col) + strconv.Itoa(r+1)
+			switch val := v.(type) {
+			case nil:
+				continue
+			case float64:
+				fmt.Fprintf(&sb, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(val, 'f', -1, 64))
+			case int:
+				fmt.Fprintf(&sb, `<c r="%s"><v>%d</v></c>`, ref, val)
+			default:
+				fmt.Fprintf(&sb, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xlsxEscape(fmt.Sprint(val)))
+			}
+		}
+		sb.WriteString(`</row>`)
+	}
+	sb.WriteString(`</sheetData></worksheet>`)
+	return sb.String()
+}
+
+func xlsxEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// xlsxSheetName 工作表名稱最多 31 字，且不能含有 []:*?/\
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadSheetSkipsLeadingBlankRows(t *testing.T) {
+	var buf bytes.Buffer
+	rows := [][]interface{}{
+		{},
+		{nil, ""},
+		{"ID", "HW1"},
+		{"s1", 80.0},
+		{},
+		{"s2", "EX"},
+	}
+	if err := WriteXLSX(&buf, "成績", rows); err != nil {
+		t.Fatal(err)
+	}
+	book, err := OpenXLSX(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, firstRow, err := book.ReadSheet("成績")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstRow != 3 {
+		t.Errorf("標題列的列號為 %d，應為 3", firstRow)
+	}
+	want := [][]string{{"ID", "HW1"}, {"s1", "80"}, nil, {"s2", "EX"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("讀出 %q，應為 %q", records, want)
+	}
+}